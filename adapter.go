@@ -0,0 +1,275 @@
+package memdb
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Adapter is an Item of any struct, pointer to struct or map with string keys. Fields are
+// named by their json tags or Go names, and a path like address.city selects a field of a
+// nested struct or map. Missing map keys and fields behind nil pointers are nil, unknown
+// struct fields panic. Copy stores a deep copy of the value.
+type Adapter struct {
+	Value interface{}
+}
+
+// Adapt returns an Item of the value v.
+func Adapt(v interface{}) Adapter {
+	return Adapter{Value: v}
+}
+
+// fields caches the field indexes of struct types by name.
+var fields sync.Map
+
+func fieldsOf(t reflect.Type) map[string][]int {
+	if m, ok := fields.Load(t); ok {
+		return m.(map[string][]int)
+	}
+	m := map[string][]int{}
+	depth := map[string]int{}
+	for _, f := range reflect.VisibleFields(t) {
+		if f.PkgPath != "" || f.Anonymous && f.Tag.Get("json") == "" {
+			continue
+		}
+		name := fieldName(f)
+		if name == "" || !exported(t, f.Index) {
+			continue
+		}
+		if d, ok := depth[name]; !ok || len(f.Index) < d {
+			m[name], depth[name] = f.Index, len(f.Index)
+		}
+	}
+	fields.Store(t, m)
+	return m
+}
+
+// exported tells if the embedded structs on the way to a promoted field are exported.
+func exported(t reflect.Type, index []int) bool {
+	for n := 1; n < len(index); n++ {
+		if t.FieldByIndex(index[:n]).PkgPath != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func (a Adapter) Field(name string) interface{} {
+	v := reflect.ValueOf(a.Value)
+	for _, part := range strings.Split(name, ".") {
+		v = field(v, part, name)
+		if !v.IsValid() {
+			return nil
+		}
+	}
+	return v.Interface()
+}
+
+func field(v reflect.Value, part, name string) reflect.Value {
+	v = indirect(v)
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			panic(name)
+		}
+		return v.MapIndex(reflect.ValueOf(part).Convert(v.Type().Key()))
+	case reflect.Struct:
+		index, ok := fieldsOf(v.Type())[part]
+		if !ok {
+			panic(name)
+		}
+		for n, i := range index {
+			if n > 0 {
+				if v = indirect(v); !v.IsValid() {
+					return v
+				}
+			}
+			v = v.Field(i)
+		}
+		return v
+	case reflect.Invalid:
+		return v
+	}
+	panic(name)
+}
+
+// indirect follows pointers and interfaces, it returns the zero Value for nil.
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func (a Adapter) Copy(Item) (Item, bool) {
+	if a.Value == nil {
+		return a, true
+	}
+	return Adapter{Value: deepCopy(reflect.ValueOf(a.Value), map[pointer]reflect.Value{}).Interface()}, true
+}
+
+// pointer tells copied pointers apart, a struct and its first field share the address.
+type pointer struct {
+	reflect.Type
+	uintptr
+}
+
+// deepCopy copies pointers, slices, maps and the exported fields of structs, the copies of
+// pointers are shared like the originals. Unexported fields are copied shallow.
+func deepCopy(v reflect.Value, seen map[pointer]reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		p := pointer{v.Type(), v.Pointer()}
+		if c, ok := seen[p]; ok {
+			return c
+		}
+		c := reflect.New(v.Type().Elem())
+		seen[p] = c
+		c.Elem().Set(deepCopy(v.Elem(), seen))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(deepCopy(v.Elem(), seen))
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for n := 0; n < v.Len(); n++ {
+			c.Index(n).Set(deepCopy(v.Index(n), seen))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for it := v.MapRange(); it.Next(); {
+			c.SetMapIndex(it.Key(), deepCopy(it.Value(), seen))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for n := 0; n < v.Len(); n++ {
+			c.Index(n).Set(deepCopy(v.Index(n), seen))
+		}
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for n := 0; n < v.NumField(); n++ {
+			if c.Field(n).CanSet() {
+				c.Field(n).Set(deepCopy(v.Field(n), seen))
+			}
+		}
+		return c
+	}
+	return v
+}
+
+// Set returns a deep copy of the item with the field set to value, nil sets the zero value.
+func (a Adapter) Set(name string, value interface{}) (Item, bool) {
+	if a.Value == nil {
+		return a, false
+	}
+	c := reflect.New(reflect.TypeOf(a.Value)).Elem()
+	c.Set(deepCopy(reflect.ValueOf(a.Value), map[pointer]reflect.Value{}))
+	if !set(c, strings.Split(name, "."), value) {
+		return a, false
+	}
+	return Adapter{Value: c.Interface()}, true
+}
+
+func set(v reflect.Value, path []string, value interface{}) bool {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return false
+		}
+		if v.Kind() == reflect.Interface {
+			c := reflect.New(v.Elem().Type()).Elem()
+			c.Set(v.Elem())
+			if !set(c, path, value) {
+				return false
+			}
+			v.Set(c)
+			return true
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.IsNil() || v.Type().Key().Kind() != reflect.String {
+			return false
+		}
+		key := reflect.ValueOf(path[0]).Convert(v.Type().Key())
+		if len(path) > 1 {
+			c := reflect.New(v.Type().Elem()).Elem()
+			if e := v.MapIndex(key); e.IsValid() {
+				c.Set(e)
+			}
+			if !set(c, path[1:], value) {
+				return false
+			}
+			v.SetMapIndex(key, c)
+			return true
+		}
+		x, ok := assignable(value, v.Type().Elem())
+		if ok {
+			v.SetMapIndex(key, x)
+		}
+		return ok
+	case reflect.Struct:
+		index, ok := fieldsOf(v.Type())[path[0]]
+		if !ok {
+			return false
+		}
+		for n, i := range index {
+			if n > 0 {
+				for v.Kind() == reflect.Ptr {
+					if v.IsNil() {
+						return false
+					}
+					v = v.Elem()
+				}
+			}
+			v = v.Field(i)
+		}
+		if len(path) > 1 {
+			return set(v, path[1:], value)
+		}
+		x, ok := assignable(value, v.Type())
+		if ok && v.CanSet() {
+			v.Set(x)
+			return true
+		}
+	}
+	return false
+}
+
+func assignable(value interface{}, t reflect.Type) (reflect.Value, bool) {
+	if value == nil {
+		return reflect.Zero(t), true
+	}
+	v := reflect.ValueOf(value)
+	if v.Type().AssignableTo(t) {
+		return v, true
+	}
+	if numeric(v.Kind()) && numeric(t.Kind()) {
+		return v.Convert(t), true
+	}
+	return reflect.Value{}, false
+}
+
+func numeric(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Float64
+}
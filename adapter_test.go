@@ -0,0 +1,97 @@
+package memdb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type Address struct {
+	City   string   `json:"city"`
+	Street string   `json:"street"`
+	Tags   []string `json:"tags"`
+}
+
+type Meta struct {
+	Version int
+}
+
+type A1 struct {
+	ID      int      `json:"id"`
+	Name    string   `json:"name"`
+	Address *Address `json:"address"`
+	Meta
+	secret string
+}
+
+func TestAdapter(t *testing.T) {
+	a := A1{ID: 1, Name: "one", Address: &Address{City: "Paris", Tags: []string{"a"}}, Meta: Meta{Version: 2}}
+	item := Adapt(&a)
+	require.Equal(t, 1, item.Field("id"))
+	require.Equal(t, "Paris", item.Field("address.city"))
+	require.Equal(t, []string{"a"}, item.Field("address.tags"))
+	require.Equal(t, 2, item.Field("Version"))
+	require.Panics(t, func() { item.Field("secret") })
+	require.Panics(t, func() { item.Field("address.zip") })
+	require.Nil(t, Adapt(A1{}).Field("address.city"))
+
+	m := Adapt(map[string]interface{}{"id": 2, "address": map[string]interface{}{"city": "Rome"}})
+	require.Equal(t, "Rome", m.Field("address.city"))
+	require.Nil(t, m.Field("address.street"))
+	require.Nil(t, m.Field("name"))
+
+	collection := newIndexed(
+		Index{
+			Field:   []string{"address.city"},
+			Mapper:  &NonUniqueIndex{},
+			Indexer: Format,
+		},
+	)
+	for _, item := range []Item{item, m} {
+		_, ok := collection.Put(&Tx{}, item, 0)
+		require.True(t, ok)
+	}
+	a.Address.City = "Berlin"
+	a.Address.Tags[0] = "b"
+	items := collection.Get(&Tx{}, 1, []interface{}{"Paris"})
+	require.Len(t, items, 1)
+	stored := items[0].(Adapter).Value.(*A1)
+	require.NotSame(t, &a, stored)
+	require.Equal(t, "Paris", stored.Address.City)
+	require.Equal(t, []string{"a"}, stored.Address.Tags)
+	require.Len(t, collection.Get(&Tx{}, 1, []interface{}{"Rome"}), 1)
+
+	set, ok := item.Set("address.city", "Oslo")
+	require.True(t, ok)
+	require.Equal(t, "Oslo", set.Field("address.city"))
+	require.Equal(t, "Berlin", item.Field("address.city"))
+	set, ok = set.(Adapter).Set("address", nil)
+	require.True(t, ok)
+	require.Nil(t, set.Field("address.city"))
+	_, ok = item.Set("id", "one")
+	require.False(t, ok)
+}
+
+func TestAdapter_Copy_aliased(t *testing.T) {
+	type inner struct {
+		N int
+	}
+	type outer struct {
+		P *inner
+		Q *int
+	}
+	p := &inner{N: 1}
+	item, ok := Adapt(outer{P: p, Q: &p.N}).Copy(nil)
+	require.True(t, ok)
+	copied := item.(Adapter).Value.(outer)
+	require.Equal(t, 1, copied.P.N)
+	require.Equal(t, 1, *copied.Q)
+	require.NotSame(t, p, copied.P)
+}
+
+func BenchmarkAdapter_Field(b *testing.B) {
+	item := Adapt(&A1{ID: 1, Address: &Address{City: "Paris"}})
+	for i := 0; i < b.N; i++ {
+		item.Field("address.city")
+	}
+}
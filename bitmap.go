@@ -0,0 +1,296 @@
+package memdb
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// arrayMax is the cardinality above which a container switches from a sorted array to a bitset.
+const arrayMax = 4096
+
+// Bitmap is a compressed set of 32-bit row ordinals. Values sharing the high 16 bits go into one
+// container, which is either a sorted array of the low bits or a 65536-bit bitset.
+type Bitmap struct {
+	keys       []uint16
+	containers []*container
+}
+
+type container struct {
+	array  []uint16
+	bitset []uint64
+	n      int
+}
+
+func (b *Bitmap) find(high uint16) (int, bool) {
+	k := sort.Search(len(b.keys), func(k int) bool {
+		return b.keys[k] >= high
+	})
+	return k, k < len(b.keys) && b.keys[k] == high
+}
+
+// Add puts x into the bitmap.
+func (b *Bitmap) Add(x uint32) {
+	high, low := uint16(x>>16), uint16(x)
+	k, ok := b.find(high)
+	if !ok {
+		b.keys = append(b.keys, 0)
+		b.containers = append(b.containers, nil)
+		copy(b.keys[k+1:], b.keys[k:])
+		copy(b.containers[k+1:], b.containers[k:])
+		b.keys[k], b.containers[k] = high, &container{}
+	}
+	b.containers[k].add(low)
+}
+
+// Remove deletes x from the bitmap.
+func (b *Bitmap) Remove(x uint32) {
+	high, low := uint16(x>>16), uint16(x)
+	k, ok := b.find(high)
+	if !ok {
+		return
+	}
+	c := b.containers[k]
+	c.remove(low)
+	if c.n == 0 {
+		b.keys = append(b.keys[:k], b.keys[k+1:]...)
+		b.containers = append(b.containers[:k], b.containers[k+1:]...)
+	}
+}
+
+// Contains tells if x is in the bitmap.
+func (b *Bitmap) Contains(x uint32) bool {
+	k, ok := b.find(uint16(x >> 16))
+	return ok && b.containers[k].contains(uint16(x))
+}
+
+// Cardinality returns the number of values in the bitmap.
+func (b *Bitmap) Cardinality() (n int) {
+	for _, c := range b.containers {
+		n += c.n
+	}
+	return
+}
+
+// Iterate calls f for every value in ascending order until f returns false.
+func (b *Bitmap) Iterate(f func(uint32) bool) {
+	for k, c := range b.containers {
+		high := uint32(b.keys[k]) << 16
+		if !c.iterate(func(low uint16) bool {
+			return f(high | uint32(low))
+		}) {
+			return
+		}
+	}
+}
+
+// Clone returns a copy of the bitmap.
+func (b *Bitmap) Clone() *Bitmap {
+	clone := &Bitmap{keys: append([]uint16(nil), b.keys...)}
+	for _, c := range b.containers {
+		clone.containers = append(clone.containers, c.clone())
+	}
+	return clone
+}
+
+// And returns the values present in both bitmaps.
+func (b *Bitmap) And(o *Bitmap) *Bitmap {
+	r := &Bitmap{}
+	for k, high := range b.keys {
+		if j, ok := o.find(high); ok {
+			r.push(high, combine(b.containers[k], o.containers[j], func(x, y uint64) uint64 { return x & y }))
+		}
+	}
+	return r
+}
+
+// Or returns the values present in either bitmap.
+func (b *Bitmap) Or(o *Bitmap) *Bitmap {
+	r := &Bitmap{}
+	k, j := 0, 0
+	for k < len(b.keys) || j < len(o.keys) {
+		switch {
+		case j == len(o.keys) || k < len(b.keys) && b.keys[k] < o.keys[j]:
+			r.push(b.keys[k], b.containers[k].clone())
+			k++
+		case k == len(b.keys) || o.keys[j] < b.keys[k]:
+			r.push(o.keys[j], o.containers[j].clone())
+			j++
+		default:
+			r.push(b.keys[k], combine(b.containers[k], o.containers[j], func(x, y uint64) uint64 { return x | y }))
+			k++
+			j++
+		}
+	}
+	return r
+}
+
+// AndNot returns the values of b absent from o.
+func (b *Bitmap) AndNot(o *Bitmap) *Bitmap {
+	r := &Bitmap{}
+	for k, high := range b.keys {
+		if j, ok := o.find(high); ok {
+			r.push(high, combine(b.containers[k], o.containers[j], func(x, y uint64) uint64 { return x &^ y }))
+		} else {
+			r.push(high, b.containers[k].clone())
+		}
+	}
+	return r
+}
+
+func (b *Bitmap) push(high uint16, c *container) {
+	if c.n > 0 {
+		b.keys = append(b.keys, high)
+		b.containers = append(b.containers, c)
+	}
+}
+
+func (c *container) search(low uint16) (int, bool) {
+	k := sort.Search(len(c.array), func(k int) bool {
+		return c.array[k] >= low
+	})
+	return k, k < len(c.array) && c.array[k] == low
+}
+
+func (c *container) add(low uint16) {
+	if c.bitset != nil {
+		if c.bitset[low>>6]&(1<<(low&63)) == 0 {
+			c.bitset[low>>6] |= 1 << (low & 63)
+			c.n++
+		}
+		return
+	}
+	k, ok := c.search(low)
+	if ok {
+		return
+	}
+	c.array = append(c.array, 0)
+	copy(c.array[k+1:], c.array[k:])
+	c.array[k] = low
+	c.n++
+	if c.n > arrayMax {
+		c.bitset, c.array = c.words(), nil
+	}
+}
+
+func (c *container) remove(low uint16) {
+	if c.bitset != nil {
+		if c.bitset[low>>6]&(1<<(low&63)) != 0 {
+			c.bitset[low>>6] &^= 1 << (low & 63)
+			c.n--
+			c.shrink()
+		}
+		return
+	}
+	if k, ok := c.search(low); ok {
+		c.array = append(c.array[:k], c.array[k+1:]...)
+		c.n--
+	}
+}
+
+func (c *container) contains(low uint16) bool {
+	if c.bitset != nil {
+		return c.bitset[low>>6]&(1<<(low&63)) != 0
+	}
+	_, ok := c.search(low)
+	return ok
+}
+
+func (c *container) iterate(f func(uint16) bool) bool {
+	if c.bitset == nil {
+		for _, low := range c.array {
+			if !f(low) {
+				return false
+			}
+		}
+		return true
+	}
+	for k, word := range c.bitset {
+		for word != 0 {
+			t := bits.TrailingZeros64(word)
+			if !f(uint16(k<<6 + t)) {
+				return false
+			}
+			word &= word - 1
+		}
+	}
+	return true
+}
+
+func (c *container) clone() *container {
+	clone := &container{n: c.n}
+	if c.bitset != nil {
+		clone.bitset = append([]uint64(nil), c.bitset...)
+	} else {
+		clone.array = append([]uint16(nil), c.array...)
+	}
+	return clone
+}
+
+// words returns the container as a bitset.
+func (c *container) words() []uint64 {
+	if c.bitset != nil {
+		return c.bitset
+	}
+	words := make([]uint64, 1024)
+	for _, low := range c.array {
+		words[low>>6] |= 1 << (low & 63)
+	}
+	return words
+}
+
+// shrink turns a sparse bitset back into an array.
+func (c *container) shrink() {
+	if c.bitset == nil || c.n > arrayMax {
+		return
+	}
+	array := make([]uint16, 0, c.n)
+	c.iterate(func(low uint16) bool {
+		array = append(array, low)
+		return true
+	})
+	c.array, c.bitset = array, nil
+}
+
+func combine(a, b *container, op func(x, y uint64) uint64) *container {
+	if a.bitset == nil && b.bitset == nil {
+		return merge(a.array, b.array, op)
+	}
+	x, y := a.words(), b.words()
+	r := &container{bitset: make([]uint64, 1024)}
+	for k := range r.bitset {
+		r.bitset[k] = op(x[k], y[k])
+		r.n += bits.OnesCount64(r.bitset[k])
+	}
+	r.shrink()
+	return r
+}
+
+// merge combines two arrays, op is evaluated on the lowest bits telling membership of a value.
+func merge(a, b []uint16, op func(x, y uint64) uint64) *container {
+	r := &container{}
+	k, j := 0, 0
+	for k < len(a) || j < len(b) {
+		var x, y uint64
+		var low uint16
+		switch {
+		case j == len(b) || k < len(a) && a[k] < b[j]:
+			x, low = 1, a[k]
+			k++
+		case k == len(a) || b[j] < a[k]:
+			y, low = 1, b[j]
+			j++
+		default:
+			x, y, low = 1, 1, a[k]
+			k++
+			j++
+		}
+		if op(x, y)&1 != 0 {
+			r.array = append(r.array, low)
+			r.n++
+		}
+	}
+	if r.n > arrayMax {
+		r.bitset, r.array = r.words(), nil
+	}
+	return r
+}
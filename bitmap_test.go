@@ -0,0 +1,125 @@
+package memdb
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func bitmapOf(values map[uint32]bool) *Bitmap {
+	b := &Bitmap{}
+	for x := range values {
+		b.Add(x)
+	}
+	return b
+}
+
+func bitmapValues(b *Bitmap) map[uint32]bool {
+	values := map[uint32]bool{}
+	b.Iterate(func(x uint32) bool {
+		values[x] = true
+		return true
+	})
+	return values
+}
+
+func TestBitmap(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	x, y := map[uint32]bool{}, map[uint32]bool{}
+	for i := 0; i < 20000; i++ {
+		x[uint32(r.Intn(1<<17))] = true
+		y[uint32(r.Intn(1<<18))] = true
+	}
+	for i := uint32(0); i < 100; i++ {
+		x[1<<20+i*7] = true
+		y[1<<20+i*5] = true
+	}
+	and, or, not := map[uint32]bool{}, map[uint32]bool{}, map[uint32]bool{}
+	for v := range x {
+		or[v] = true
+		if y[v] {
+			and[v] = true
+		} else {
+			not[v] = true
+		}
+	}
+	for v := range y {
+		or[v] = true
+	}
+	a, b := bitmapOf(x), bitmapOf(y)
+	require.Equal(t, len(x), a.Cardinality())
+	require.Equal(t, and, bitmapValues(a.And(b)))
+	require.Equal(t, or, bitmapValues(a.Or(b)))
+	require.Equal(t, not, bitmapValues(a.AndNot(b)))
+	require.Equal(t, len(and), a.And(b).Cardinality())
+	for v := range x {
+		a.Remove(v)
+		require.False(t, a.Contains(v))
+	}
+	require.Zero(t, a.Cardinality())
+	require.True(t, b.Contains(1<<20+5))
+}
+
+func TestCollection_Select(t *testing.T) {
+	collection := newIndexed(
+		Index{
+			Field:   []string{"type"},
+			Mapper:  &BitmapIndex{},
+			Indexer: Format,
+		},
+		Index{
+			Field:   []string{"code"},
+			Mapper:  &BitmapIndex{},
+			Indexer: Format,
+		},
+	)
+	var items []Item
+	for i := 0; i < 12; i++ {
+		item := X1{ID: uuid.New(), Type: []string{"audio", "video", "image"}[i%3], Code: i % 2}
+		_, ok := collection.Put(&Tx{}, item, 0)
+		require.True(t, ok)
+		items = append(items, item)
+	}
+	audio := collection.Bitmap(1, "audio")
+	odd := collection.Bitmap(2, 1)
+	require.Equal(t, 4, audio.Cardinality())
+	require.ElementsMatch(t, []Item{items[3], items[9]}, collection.Select(&Tx{}, audio.And(odd)))
+	require.Len(t, collection.Select(&Tx{}, audio.Or(odd)), 8)
+	require.ElementsMatch(t, []Item{items[0], items[6]}, collection.Select(&Tx{}, audio.AndNot(odd)))
+	require.Len(t, collection.Select(&Tx{}, collection.Universe().AndNot(audio)), 8)
+	require.ElementsMatch(t, []Item{items[0], items[3], items[6], items[9]}, collection.Get(&Tx{}, 1, []interface{}{"audio"}))
+	_, ok := collection.Delete(&Tx{}, items[3], 0)
+	require.True(t, ok)
+	_, ok = collection.Put(&Tx{}, X1{ID: items[0].(X1).ID, Type: "video", Code: 1}, 0)
+	require.True(t, ok)
+	require.ElementsMatch(t, []Item{items[9]}, collection.Select(&Tx{}, collection.Bitmap(1, "audio").And(collection.Bitmap(2, 1))))
+	require.Equal(t, 11, collection.Universe().Cardinality())
+}
+
+func TestCollection_Select_online(t *testing.T) {
+	collection := newCollection(t)
+	var items []Item
+	for i := 0; i < 6; i++ {
+		item := X1{ID: uuid.New(), Type: []string{"audio", "video"}[i%2], Code: i, Name: i}
+		_, ok := collection.Put(&Tx{}, item, 0)
+		require.True(t, ok)
+		items = append(items, item)
+	}
+	require.Zero(t, collection.Universe().Cardinality())
+	i, err := collection.AddIndex(&Tx{}, Index{
+		Field:   []string{"type"},
+		Mapper:  &BitmapIndex{},
+		Indexer: Format,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 6, collection.Universe().Cardinality())
+	require.ElementsMatch(t, []Item{items[0], items[2], items[4]}, collection.Select(&Tx{}, collection.Bitmap(i, "audio")))
+	_, ok := collection.Delete(&Tx{}, items[0], 0)
+	require.True(t, ok)
+	_, ok = collection.Delete(&Tx{}, items[0], 0)
+	require.False(t, ok)
+	require.Len(t, collection.ordinals.free, 1)
+	require.Equal(t, 5, collection.Universe().Cardinality())
+}
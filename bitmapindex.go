@@ -0,0 +1,186 @@
+package memdb
+
+import "sync"
+
+// BitmapIndex keeps a bitmap of row ordinals per key. It suits fields with few distinct values
+// shared by many rows, and bitmaps of several indexes combine cheaply.
+type BitmapIndex struct {
+	mx     sync.RWMutex
+	keys   map[interface{}]*Bitmap
+	values map[uint32]interface{}
+	refs   map[uint32]int
+	counter
+}
+
+// ordinals hands out row ordinals and resolves them back to rows. Rows get an ordinal only
+// while the collection has a bitmap index, ordinal 0 means none.
+type ordinals struct {
+	mx   sync.RWMutex
+	next uint32
+	free []uint32
+	rows map[uint32]*Row
+	all  Bitmap
+}
+
+func (o *ordinals) acquire(row *Row) {
+	o.mx.Lock()
+	defer o.mx.Unlock()
+	if row.ord != 0 {
+		return
+	}
+	if o.rows == nil {
+		o.rows = make(map[uint32]*Row)
+	}
+	if n := len(o.free); n > 0 {
+		row.ord, o.free = o.free[n-1], o.free[:n-1]
+	} else {
+		o.next++
+		row.ord = o.next
+	}
+	o.rows[row.ord] = row
+	o.all.Add(row.ord)
+}
+
+func (o *ordinals) release(row *Row) {
+	if row.ord == 0 {
+		return
+	}
+	o.mx.Lock()
+	defer o.mx.Unlock()
+	if o.rows[row.ord] != row {
+		return
+	}
+	delete(o.rows, row.ord)
+	o.all.Remove(row.ord)
+	o.free = append(o.free, row.ord)
+	row.ord = 0
+}
+
+// bitmapped tells if the collection has a bitmap index, the caller holds the lock.
+func (c *Collection) bitmapped() bool {
+	for _, index := range c.Indexes {
+		if _, ok := index.Mapper.(*BitmapIndex); ok {
+			return true
+		}
+	}
+	for _, b := range c.building {
+		if _, ok := b.Mapper.(*BitmapIndex); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *ordinals) lookup(b *Bitmap) (rows []interface{}) {
+	o.mx.RLock()
+	defer o.mx.RUnlock()
+	b.Iterate(func(ord uint32) bool {
+		if row, ok := o.rows[ord]; ok {
+			rows = append(rows, row)
+		}
+		return true
+	})
+	return
+}
+
+// Bitmap returns the ordinals of rows stored under values in the bitmap index i.
+func (c *Collection) Bitmap(i int, values ...interface{}) *Bitmap {
+	index := c.index(i)
+	return index.Mapper.(*BitmapIndex).Bitmap(index.Index(values...))
+}
+
+// Universe returns the ordinals of all rows of a collection with a bitmap index,
+// Universe().AndNot(b) is the complement of b.
+func (c *Collection) Universe() *Bitmap {
+	c.ordinals.mx.RLock()
+	defer c.ordinals.mx.RUnlock()
+	return c.ordinals.all.Clone()
+}
+
+// Select returns items of rows in the bitmap.
+func (c *Collection) Select(tx *Tx, b *Bitmap) []Item {
+	return c.items(tx, c.ordinals.lookup(b))
+}
+
+// Bitmap returns a copy of the bitmap stored under key.
+func (i *BitmapIndex) Bitmap(key interface{}) *Bitmap {
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	if b, ok := i.keys[key]; ok {
+		return b.Clone()
+	}
+	return &Bitmap{}
+}
+
+func (i *BitmapIndex) Load(key interface{}) (values []interface{}, ok bool) {
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	b, ok := i.keys[key]
+	if ok {
+		b.Iterate(func(ord uint32) bool {
+			values = append(values, i.values[ord])
+			return true
+		})
+	}
+	return
+}
+
+func (i *BitmapIndex) LoadOrStore(key, value interface{}) (interface{}, bool) {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	if i.keys == nil {
+		i.keys = make(map[interface{}]*Bitmap)
+		i.values = make(map[uint32]interface{})
+		i.refs = make(map[uint32]int)
+	}
+	ord := value.(*Row).ord
+	b, ok := i.keys[key]
+	if !ok {
+		b = &Bitmap{}
+		i.keys[key] = b
+	}
+	if b.Contains(ord) {
+		return value, true
+	}
+	b.Add(ord)
+	i.values[ord] = value
+	i.refs[ord]++
+	i.add(key, int64(b.Cardinality()))
+	return value, false
+}
+
+func (i *BitmapIndex) LoadAndDelete(key, value interface{}) (interface{}, bool) {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	ord := value.(*Row).ord
+	b, ok := i.keys[key]
+	if !ok || !b.Contains(ord) {
+		return nil, false
+	}
+	b.Remove(ord)
+	n := b.Cardinality()
+	if n == 0 {
+		delete(i.keys, key)
+	}
+	if i.refs[ord]--; i.refs[ord] == 0 {
+		delete(i.refs, ord)
+		delete(i.values, ord)
+	}
+	i.remove(key, int64(n))
+	return value, true
+}
+
+func (i *BitmapIndex) Range(f func(key, value interface{}) bool) {
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	for key, b := range i.keys {
+		ok := true
+		b.Iterate(func(ord uint32) bool {
+			ok = f(key, i.values[ord])
+			return ok
+		})
+		if !ok {
+			return
+		}
+	}
+}
@@ -0,0 +1,356 @@
+// Memdbgen generates Item implementations and typed collections for structs tagged for
+// memdb.SchemaOf, so that field names are checked at compile time instead of by reflection.
+//
+// Usage:
+//
+//	//go:generate memdbgen -type Order,Customer
+//
+// For every type it writes the Field and Copy methods, a collection type named after it with
+// the constructor New<Type>Collection and a Get<By>Fields method per index, where unique
+// indexes return a single item. Copy duplicates the slices, maps and pointers of the fields.
+// The output goes to <type>_memdb.go, or <type>_memdb_test.go for types declared in tests.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pshvedko/memdb"
+)
+
+func main() {
+	names := flag.String("type", "", "comma separated list of struct type names")
+	output := flag.String("output", "", "output file name")
+	flag.Parse()
+	if *names == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+	src, name, err := generate(dir, strings.Split(*names, ","))
+	if err == nil {
+		if *output == "" {
+			*output = filepath.Join(dir, name)
+		}
+		err = os.WriteFile(*output, src, 0644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "memdbgen:", err)
+		os.Exit(1)
+	}
+}
+
+type field struct {
+	name string
+	key  string
+	typ  ast.Expr
+}
+
+type structType struct {
+	name   string
+	fields []field
+	schema memdb.Schema
+	file   *ast.File
+}
+
+// generate returns the source of the types declared in the package in dir and its file name.
+func generate(dir string, names []string) ([]byte, string, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_memdb.go") && !strings.HasSuffix(info.Name(), "_memdb_test.go")
+	}, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	var structs []structType
+	var pkg string
+	test := false
+	for _, name := range names {
+		s, p, file, err := find(pkgs, name)
+		if err != nil {
+			return nil, "", err
+		}
+		pkg = p
+		test = test || strings.HasSuffix(fset.File(file.Pos()).Name(), "_test.go")
+		structs = append(structs, s)
+	}
+	g := generator{imports: map[string]string{}}
+	if pkg != "memdb" {
+		g.memdb = "memdb."
+		g.imports["memdb"] = strconv.Quote("github.com/pshvedko/memdb")
+	}
+	var body bytes.Buffer
+	for _, s := range structs {
+		g.generate(&body, s)
+	}
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by memdbgen -type %s; DO NOT EDIT.\n\npackage %s\n\n", strings.Join(names, ","), pkg)
+	if len(g.imports) > 0 {
+		var std, other []string
+		for _, spec := range g.imports {
+			if p := spec[strings.IndexByte(spec, '"'):]; strings.Contains(strings.Split(p, "/")[0], ".") {
+				other = append(other, spec)
+			} else {
+				std = append(std, spec)
+			}
+		}
+		sort.Strings(std)
+		sort.Strings(other)
+		var groups []string
+		for _, group := range [][]string{std, other} {
+			if len(group) > 0 {
+				groups = append(groups, strings.Join(group, "\n"))
+			}
+		}
+		fmt.Fprintf(&out, "import (\n%s\n)\n\n", strings.Join(groups, "\n\n"))
+	}
+	out.Write(body.Bytes())
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, "", err
+	}
+	name := strings.ToLower(names[0]) + "_memdb.go"
+	if test {
+		name = strings.ToLower(names[0]) + "_memdb_test.go"
+	}
+	return src, name, nil
+}
+
+func find(pkgs map[string]*ast.Package, name string) (structType, string, *ast.File, error) {
+	for pkg, p := range pkgs {
+		for _, file := range p.Files {
+			for _, decl := range file.Decls {
+				decl, ok := decl.(*ast.GenDecl)
+				if !ok || decl.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range decl.Specs {
+					spec := spec.(*ast.TypeSpec)
+					if spec.Name.Name != name {
+						continue
+					}
+					st, ok := spec.Type.(*ast.StructType)
+					if !ok {
+						return structType{}, "", nil, fmt.Errorf("%s is not a struct", name)
+					}
+					s, err := parse(name, st)
+					s.file = file
+					return s, pkg, file, err
+				}
+			}
+		}
+	}
+	return structType{}, "", nil, fmt.Errorf("type %s not found", name)
+}
+
+// parse collects the stored fields of a struct and derives its schema with memdb.SchemaOf
+// from a struct of the same field names and tags.
+func parse(name string, st *ast.StructType) (structType, error) {
+	s := structType{name: name}
+	var fields []reflect.StructField
+	for _, f := range st.Fields.List {
+		var tag reflect.StructTag
+		if f.Tag != nil {
+			raw, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				return s, err
+			}
+			tag = reflect.StructTag(raw)
+		}
+		for _, ident := range f.Names {
+			if !ident.IsExported() {
+				if _, ok := tag.Lookup("memdb"); ok {
+					return s, fmt.Errorf("%s.%s is not exported", name, ident.Name)
+				}
+				continue
+			}
+			key := strings.Split(tag.Get("json"), ",")[0]
+			switch key {
+			case "-":
+				continue
+			case "":
+				key = ident.Name
+			}
+			s.fields = append(s.fields, field{name: ident.Name, key: key, typ: f.Type})
+			fields = append(fields, reflect.StructField{Name: ident.Name, Type: reflect.TypeOf(0), Tag: tag})
+		}
+	}
+	schema, err := memdb.SchemaOf(reflect.New(reflect.StructOf(fields)).Interface())
+	if err != nil {
+		return s, fmt.Errorf("%s: %w", name, err)
+	}
+	schema.Name, schema.Type = name, nil
+	s.schema = schema
+	return s, nil
+}
+
+type generator struct {
+	memdb   string
+	imports map[string]string
+}
+
+// typ returns the source of a type expression and imports the packages it refers to.
+func (g *generator) typ(s structType, expr ast.Expr) string {
+	ast.Inspect(expr, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if x, ok := sel.X.(*ast.Ident); ok {
+			for _, spec := range s.file.Imports {
+				if importName(spec) == x.Name {
+					g.imports[x.Name] = importSpec(spec)
+				}
+			}
+		}
+		return false
+	})
+	return types.ExprString(expr)
+}
+
+var version = regexp.MustCompile(`^v[0-9]+$`)
+
+func importName(spec *ast.ImportSpec) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	p, _ := strconv.Unquote(spec.Path.Value)
+	name := path.Base(p)
+	if version.MatchString(name) {
+		name = path.Base(path.Dir(p))
+	}
+	if n := strings.IndexAny(name, ".-"); n >= 0 {
+		name = name[:n]
+	}
+	return name
+}
+
+func importSpec(spec *ast.ImportSpec) string {
+	if spec.Name != nil {
+		return spec.Name.Name + " " + spec.Path.Value
+	}
+	return spec.Path.Value
+}
+
+func (g *generator) generate(w *bytes.Buffer, s structType) {
+	m := g.memdb
+	fmt.Fprintf(w, "func (x %s) Field(name string) interface{} {\n\tswitch name {\n", s.name)
+	for _, f := range s.fields {
+		fmt.Fprintf(w, "\tcase %q:\n\t\treturn x.%s\n", f.key, f.name)
+	}
+	fmt.Fprintf(w, "\t}\n\tpanic(name)\n}\n\n")
+
+	fmt.Fprintf(w, "func (x %s) Copy(%sItem) (%sItem, bool) {\n", s.name, m, m)
+	for _, f := range s.fields {
+		switch t := f.typ.(type) {
+		case *ast.ArrayType:
+			if t.Len == nil {
+				fmt.Fprintf(w, "\tx.%s = append(%s(nil), x.%s...)\n", f.name, g.typ(s, t), f.name)
+			}
+		case *ast.MapType:
+			fmt.Fprintf(w, "\tif x.%s != nil {\n\t\tm := make(%s, len(x.%s))\n", f.name, g.typ(s, t), f.name)
+			fmt.Fprintf(w, "\t\tfor k, v := range x.%s {\n\t\t\tm[k] = v\n\t\t}\n\t\tx.%s = m\n\t}\n", f.name, f.name)
+		case *ast.StarExpr:
+			fmt.Fprintf(w, "\tif x.%s != nil {\n\t\tv := *x.%s\n\t\tx.%s = &v\n\t}\n", f.name, f.name, f.name)
+		}
+	}
+	fmt.Fprintf(w, "\treturn x, true\n}\n\n")
+
+	c := s.name + "Collection"
+	fmt.Fprintf(w, "// %s is a collection of %s items.\ntype %s struct {\n\t*%sCollection\n}\n\n", c, s.name, c, m)
+	fmt.Fprintf(w, "// New%s returns an empty collection with the indexes of %s.\n", c, s.name)
+	if len(s.schema.Immutable) == 0 {
+		fmt.Fprintf(w, "func New%s() %s {\n\treturn %s{&%sCollection{Indexes: []%sIndex{\n", c, c, c, m, m)
+	} else {
+		fmt.Fprintf(w, "func New%s() %s {\n\tc := %s{&%sCollection{Indexes: []%sIndex{\n", c, c, c, m, m)
+	}
+	for _, index := range s.schema.Indexes {
+		mapper := "NonUniqueIndex"
+		if index.Unique {
+			mapper = "UniqueIndex"
+		}
+		fmt.Fprintf(w, "\t\t{Field: []string{%s}, Mapper: &%s%s{}, Indexer: %sFormat},\n", quote(index.Field), m, mapper, m)
+	}
+	if len(s.schema.Immutable) == 0 {
+		fmt.Fprintf(w, "\t}}}\n}\n\n")
+	} else {
+		fmt.Fprintf(w, "\t}}}\n\tc.AddImmutable(%s)\n\treturn c\n}\n\n", quote(s.schema.Immutable))
+	}
+
+	byKey := map[string]field{}
+	for _, f := range s.fields {
+		byKey[f.key] = f
+	}
+	for i, index := range s.schema.Indexes {
+		var method, params, args []string
+		for _, key := range index.Field {
+			f := byKey[key]
+			name := param(f.name, s.file)
+			method = append(method, f.name)
+			params = append(params, name+" "+g.typ(s, f.typ))
+			args = append(args, name)
+		}
+		if index.Unique {
+			fmt.Fprintf(w, "// GetBy%s returns the item with the key of the %s index.\n", strings.Join(method, ""), index.Name)
+			fmt.Fprintf(w, "func (c %s) GetBy%s(tx *%sTx, %s) (%s, bool) {\n", c, strings.Join(method, ""), m, strings.Join(params, ", "), s.name)
+			fmt.Fprintf(w, "\titems := c.Get(tx, %d, []interface{}{%s})\n", i, strings.Join(args, ", "))
+			fmt.Fprintf(w, "\tif len(items) == 0 {\n\t\treturn %s{}, false\n\t}\n\treturn items[0].(%s), true\n}\n\n", s.name, s.name)
+		} else {
+			fmt.Fprintf(w, "// GetBy%s returns the items with the key of the %s index.\n", strings.Join(method, ""), index.Name)
+			fmt.Fprintf(w, "func (c %s) GetBy%s(tx *%sTx, %s) []%s {\n", c, strings.Join(method, ""), m, strings.Join(params, ", "), s.name)
+			fmt.Fprintf(w, "\titems := c.Get(tx, %d, []interface{}{%s})\n", i, strings.Join(args, ", "))
+			fmt.Fprintf(w, "\tx := make([]%s, 0, len(items))\n\tfor _, item := range items {\n", s.name)
+			fmt.Fprintf(w, "\t\tx = append(x, item.(%s))\n\t}\n\treturn x\n}\n\n", s.name)
+		}
+	}
+}
+
+func quote(s []string) string {
+	q := make([]string, 0, len(s))
+	for _, v := range s {
+		q = append(q, strconv.Quote(v))
+	}
+	return strings.Join(q, ", ")
+}
+
+// param returns a parameter name for a field which clashes with no keyword, package or local name.
+func param(name string, file *ast.File) string {
+	r := []rune(name)
+	for n := 0; n < len(r) && unicode.IsUpper(r[n]); n++ {
+		if n > 0 && n+1 < len(r) && unicode.IsLower(r[n+1]) {
+			break
+		}
+		r[n] = unicode.ToLower(r[n])
+	}
+	name = string(r)
+	clash := token.IsKeyword(name)
+	for _, spec := range file.Imports {
+		clash = clash || importName(spec) == name
+	}
+	switch name {
+	case "c", "tx", "x", "items", "item", "memdb":
+		clash = true
+	}
+	if clash {
+		name += "_"
+	}
+	return name
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	src, name, err := generate("testdata", []string{"Order"})
+	require.NoError(t, err)
+	require.Equal(t, "order_memdb.go", name)
+	golden, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	require.Equal(t, string(golden), string(src))
+
+	_, _, err = generate("testdata", []string{"Missing"})
+	require.Error(t, err)
+}
@@ -0,0 +1,20 @@
+package shop
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Order struct {
+	ID       uuid.UUID         `json:"id" memdb:"pk"`
+	Customer uuid.UUID         `json:"customer" memdb:"index;unique,group=customer_number;immutable"`
+	Number   int               `json:"number" memdb:"unique,group=customer_number"`
+	Type     string            `json:"type"`
+	Lines    []string          `json:"lines"`
+	Labels   map[string]string `json:"labels"`
+	Note     *string           `json:"note,omitempty"`
+	Time     time.Time         `json:"time" memdb:"index"`
+	Internal string            `json:"-"`
+	total    int
+}
@@ -0,0 +1,103 @@
+// Code generated by memdbgen -type Order; DO NOT EDIT.
+
+package shop
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pshvedko/memdb"
+)
+
+func (x Order) Field(name string) interface{} {
+	switch name {
+	case "id":
+		return x.ID
+	case "customer":
+		return x.Customer
+	case "number":
+		return x.Number
+	case "type":
+		return x.Type
+	case "lines":
+		return x.Lines
+	case "labels":
+		return x.Labels
+	case "note":
+		return x.Note
+	case "time":
+		return x.Time
+	}
+	panic(name)
+}
+
+func (x Order) Copy(memdb.Item) (memdb.Item, bool) {
+	x.Lines = append([]string(nil), x.Lines...)
+	if x.Labels != nil {
+		m := make(map[string]string, len(x.Labels))
+		for k, v := range x.Labels {
+			m[k] = v
+		}
+		x.Labels = m
+	}
+	if x.Note != nil {
+		v := *x.Note
+		x.Note = &v
+	}
+	return x, true
+}
+
+// OrderCollection is a collection of Order items.
+type OrderCollection struct {
+	*memdb.Collection
+}
+
+// NewOrderCollection returns an empty collection with the indexes of Order.
+func NewOrderCollection() OrderCollection {
+	c := OrderCollection{&memdb.Collection{Indexes: []memdb.Index{
+		{Field: []string{"id"}, Mapper: &memdb.UniqueIndex{}, Indexer: memdb.Format},
+		{Field: []string{"customer"}, Mapper: &memdb.NonUniqueIndex{}, Indexer: memdb.Format},
+		{Field: []string{"customer", "number"}, Mapper: &memdb.UniqueIndex{}, Indexer: memdb.Format},
+		{Field: []string{"time"}, Mapper: &memdb.NonUniqueIndex{}, Indexer: memdb.Format},
+	}}}
+	c.AddImmutable("customer")
+	return c
+}
+
+// GetByID returns the item with the key of the pk index.
+func (c OrderCollection) GetByID(tx *memdb.Tx, id uuid.UUID) (Order, bool) {
+	items := c.Get(tx, 0, []interface{}{id})
+	if len(items) == 0 {
+		return Order{}, false
+	}
+	return items[0].(Order), true
+}
+
+// GetByCustomer returns the items with the key of the customer index.
+func (c OrderCollection) GetByCustomer(tx *memdb.Tx, customer uuid.UUID) []Order {
+	items := c.Get(tx, 1, []interface{}{customer})
+	x := make([]Order, 0, len(items))
+	for _, item := range items {
+		x = append(x, item.(Order))
+	}
+	return x
+}
+
+// GetByCustomerNumber returns the item with the key of the customer_number index.
+func (c OrderCollection) GetByCustomerNumber(tx *memdb.Tx, customer uuid.UUID, number int) (Order, bool) {
+	items := c.Get(tx, 2, []interface{}{customer, number})
+	if len(items) == 0 {
+		return Order{}, false
+	}
+	return items[0].(Order), true
+}
+
+// GetByTime returns the items with the key of the time index.
+func (c OrderCollection) GetByTime(tx *memdb.Tx, time_ time.Time) []Order {
+	items := c.Get(tx, 3, []interface{}{time_})
+	x := make([]Order, 0, len(items))
+	for _, item := range items {
+		x = append(x, item.(Order))
+	}
+	return x
+}
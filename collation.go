@@ -0,0 +1,164 @@
+package memdb
+
+import (
+	"strings"
+	"unicode"
+)
+
+//go:generate go run maketables.go
+
+// Collation selects the transformations applied to string values before they are indexed
+// or looked up, so that visually equal strings map to the same key.
+type Collation uint8
+
+const (
+	// Fold compares strings case-insensitively using full case folding.
+	Fold Collation = 1 << iota
+	// NFC applies canonical decomposition followed by canonical composition.
+	NFC
+	// NFKC applies compatibility decomposition followed by canonical composition.
+	NFKC
+	// Unaccent removes nonspacing marks, so that "é" and "e" are equal.
+	Unaccent
+)
+
+// Collate returns s transformed according to the collation.
+func (c Collation) Collate(s string) string {
+	if c&(NFC|NFKC|Unaccent) == 0 {
+		if c&Fold != 0 {
+			return fold(s)
+		}
+		return s
+	}
+	// Folding comes after the decomposition, which may give uppercase letters like "ℌ" does,
+	// and its result is decomposed again, the order of NFKC_Casefold.
+	runes := decompose(s, c&NFKC != 0)
+	if c&Fold != 0 {
+		runes = decompose(fold(string(runes)), c&NFKC != 0)
+	}
+	if c&Unaccent != 0 {
+		runes = unaccent(runes)
+	}
+	reorder(runes)
+	if c&(NFC|NFKC) != 0 {
+		runes = compose(runes)
+	}
+	return string(runes)
+}
+
+func (c Collation) collate(values []interface{}) []interface{} {
+	collated := make([]interface{}, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case string:
+			collated[i] = c.Collate(v)
+		default:
+			collated[i] = v
+		}
+	}
+	return collated
+}
+
+func fold(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		if f, ok := foldings[r]; ok {
+			b.WriteString(f)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Hangul syllables are composed and decomposed algorithmically, see Unicode 3.12.
+const (
+	sBase, lBase, vBase, tBase     = 0xac00, 0x1100, 0x1161, 0x11a7
+	lCount, vCount, tCount, nCount = 19, 21, 28, 21 * 28
+	sCount                         = lCount * nCount
+)
+
+func decompose(s string, compatibility bool) []rune {
+	runes := make([]rune, 0, len(s))
+	for _, r := range s {
+		if r >= sBase && r < sBase+sCount {
+			r -= sBase
+			runes = append(runes, lBase+r/nCount, vBase+r%nCount/tCount)
+			if t := r % tCount; t != 0 {
+				runes = append(runes, tBase+t)
+			}
+			continue
+		}
+		if compatibility {
+			if d, ok := compatibilities[r]; ok {
+				runes = append(runes, []rune(d)...)
+				continue
+			}
+		}
+		if d, ok := decompositions[r]; ok {
+			runes = append(runes, []rune(d)...)
+			continue
+		}
+		runes = append(runes, r)
+	}
+	return runes
+}
+
+func unaccent(runes []rune) []rune {
+	stripped := runes[:0]
+	for _, r := range runes {
+		if !unicode.Is(unicode.Mn, r) {
+			stripped = append(stripped, r)
+		}
+	}
+	return stripped
+}
+
+// reorder puts every run of combining marks into canonical order.
+func reorder(runes []rune) {
+	for i := 1; i < len(runes); i++ {
+		ccc := combiningClasses[runes[i]]
+		if ccc == 0 {
+			continue
+		}
+		for j := i; j > 0; j-- {
+			prev := combiningClasses[runes[j-1]]
+			if prev <= ccc {
+				break
+			}
+			runes[j-1], runes[j] = runes[j], runes[j-1]
+		}
+	}
+}
+
+func compose(runes []rune) []rune {
+	composed := runes[:0]
+	starter, last := -1, -1
+	for _, r := range runes {
+		ccc := int(combiningClasses[r])
+		if starter >= 0 && (last < ccc || last == 0 && starter == len(composed)-1) {
+			if c, ok := composePair(composed[starter], r); ok {
+				composed[starter] = c
+				continue
+			}
+		}
+		if ccc == 0 {
+			starter = len(composed)
+		}
+		last = ccc
+		composed = append(composed, r)
+	}
+	return composed
+}
+
+func composePair(a, b rune) (rune, bool) {
+	switch {
+	case a >= lBase && a < lBase+lCount && b >= vBase && b < vBase+vCount:
+		return sBase + ((a-lBase)*vCount+b-vBase)*tCount, true
+	case a >= sBase && a < sBase+sCount && (a-sBase)%tCount == 0 && b > tBase && b < tBase+tCount:
+		return a + b - tBase, true
+	}
+	c, ok := compositions[[2]rune{a, b}]
+	return c, ok
+}
@@ -0,0 +1,53 @@
+package memdb
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollation_Collate(t *testing.T) {
+	tests := []struct {
+		name      string
+		collation Collation
+		s         string
+		want      string
+	}{
+		{name: "none", s: "A\u030a", want: "A\u030a"},
+		{name: "fold", collation: Fold, s: "Straße ΣΊΣΥΦΟΣ", want: "strasse σίσυφοσ"},
+		{name: "nfc", collation: NFC, s: "A\u030a\u0301 e\u0301", want: "\u01fa \u00e9"},
+		{name: "nfc reorder", collation: NFC, s: "a\u0301\u0323", want: "\u1ea1\u0301"},
+		{name: "nfc keeps compatibility", collation: NFC, s: "ﬁ①", want: "ﬁ①"},
+		{name: "fold full", collation: Fold, s: "ﬁ ẞ ΐ", want: "fi ss \u03b9\u0308\u0301"},
+		{name: "nfkc", collation: NFKC, s: "ﬁ① \uff25\u0301", want: "fi1 \u00c9"},
+		{name: "nfc hangul", collation: NFC, s: "\u1100\u1161\u11a8 \u1100\u1161", want: "\uac01 \uac00"},
+		{name: "nfkc hangul", collation: NFKC, s: "\u326e", want: "\uac00"},
+		{name: "unaccent", collation: Unaccent, s: "Crème Brûlée", want: "Creme Brulee"},
+		{name: "fold nfkc", collation: Fold | NFKC, s: "ℌ 𝐀dmin Ⅰ", want: "h admin i"},
+		{name: "all", collation: Fold | NFKC | Unaccent, s: "ＣＲÈＭＥ", want: "creme"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.collation.Collate(tt.s))
+		})
+	}
+}
+
+func TestCollection_Get_collation(t *testing.T) {
+	collection := newIndexed(
+		Index{
+			Field:     []string{"type", "name"},
+			Mapper:    &UniqueIndex{},
+			Indexer:   Format,
+			Collation: Fold | NFC,
+		},
+	)
+	item := X1{ID: uuid.New(), Type: "Café", Name: 1}
+	_, ok := collection.Put(&Tx{}, item, 0)
+	require.True(t, ok)
+	_, ok = collection.Put(&Tx{}, X1{ID: uuid.New(), Type: "CAFE\u0301", Name: 1}, 0)
+	require.False(t, ok)
+	require.Equal(t, []Item{item}, collection.Get(&Tx{}, 1, []interface{}{"café", 1}))
+	require.Empty(t, collection.Get(&Tx{}, 1, []interface{}{"cafe", 1}))
+}
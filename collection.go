@@ -1,5 +1,16 @@
 package memdb
 
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	ErrRejected = errors.New("memdb: write rejected")
+	ErrNotFound = errors.New("memdb: item not found")
+)
+
 type Mapper interface {
 	Load(key interface{}) ([]interface{}, bool)
 	LoadOrStore(interface{}, interface{}) (interface{}, bool)
@@ -19,55 +30,201 @@ type Rollback struct {
 }
 
 type Collection struct {
-	Indexes []Index
+	Indexes   []Index
+	Generator Generator
+	mx        sync.RWMutex
+	building  []*building
+	ordinals  ordinals
+	closed    bool
+	foreign   []ForeignKey
+	referrer  []referrer
+	rules     []Rule
+	immutable []string
+	version   int32
+	upgrades  map[int32]func(Item) (Item, error)
+	pending   int64
+	migrated  int64
+	failed    int64
+	running   int32
+	order     uint64
 }
 
 // Delete ...
-func (c Collection) Delete(tx *Tx, item Item, cas uint64) (uint64, bool) {
+func (c *Collection) Delete(tx *Tx, item Item, cas uint64) (uint64, bool) {
+	cas, err := c.Remove(tx, item, cas)
+	return cas, err == nil
+}
+
+// Remove is Delete which tells why the item was not deleted. The delete actions of foreign
+// keys referencing the item are applied under its row lock, when one fails the items changed
+// by the others are restored.
+func (c *Collection) Remove(tx *Tx, item Item, cas uint64) (uint64, error) {
+	if tx.closed() {
+		return 0, ErrClosed
+	}
+	defer c.rlock()()
+	var log changes
+	cas, err := c.remove(tx, item, cas, &log)
+	if err != nil {
+		log.undo(tx)
+	}
+	return cas, err
+}
+
+func (c *Collection) remove(tx *Tx, item Item, cas uint64, log *changes) (uint64, error) {
+	if c.closed {
+		return 0, ErrClosed
+	}
+	if err := c.fault(); err != nil {
+		return 0, err
+	}
 	key := c.Indexes[0].Key(item)
 	row := c.Indexes[0].Get(key)
 	if len(row) == 0 {
-		return 0, false
+		return 0, ErrNotFound
+	}
+	cas, err := c.delete(tx, key, row[0], cas, log)
+	if err := c.fault(); err != nil {
+		return 0, err
 	}
-	return c.delete(tx, key, row[0], item, cas)
+	return cas, err
 }
 
-func (c Collection) delete(tx *Tx, key string, row *Row, item Item, cas uint64) (uint64, bool) {
+func (c *Collection) delete(tx *Tx, key string, row *Row, cas uint64, log *changes) (uint64, error) {
 	row.lock(tx)
 	defer row.unlock(tx)
+	if row.cas == 0 {
+		return 0, ErrNotFound
+	}
 	if cas == 0 {
 		cas = row.cas + 1
 	} else if cas <= row.cas {
-		return 0, false
+		return 0, ErrRejected
+	}
+	item := row.item()
+	if item == nil {
+		return 0, ErrRejected
 	}
-	c.Indexes[0].LoadAndDelete(key, row)
+	if err := c.release(tx, item, log); err != nil {
+		return 0, err
+	}
+	c.Indexes[0].Pop(key, row)
 	for _, index := range c.Indexes[1:] {
-		index.LoadAndDelete(index.Key(item), row)
+		if key, ok := index.key(item, row); ok {
+			index.Pop(key, row)
+		}
+	}
+	for _, b := range c.building {
+		if key, ok := b.key(item, row); ok {
+			b.Pop(key, row)
+		}
+	}
+	c.ordinals.release(row)
+	if row.version < c.version {
+		atomic.AddInt64(&c.pending, -1)
+	}
+	if row.pager != nil {
+		row.pager.drop(row)
 	}
 	row.Item = nil
 	row.cas = 0
-	return cas, true
+	return cas, nil
 }
 
 // Get ...
-func (c Collection) Get(tx *Tx, i int, values ...[]interface{}) []Item {
-	var rows []*Row
+func (c *Collection) Get(tx *Tx, i int, values ...[]interface{}) []Item {
+	index := c.index(i)
+	var rows []interface{}
 	for _, value := range values {
-		rows = append(rows, c.Indexes[i].Get(c.Indexes[i].Index(value...))...)
+		for _, row := range index.Get(index.Index(value...)) {
+			rows = append(rows, row)
+		}
 	}
+	return c.items(tx, rows)
+}
+
+func (c *Collection) index(i int) Index {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	return c.Indexes[i]
+}
+
+// items returns the committed items of the rows, items of older versions are upgraded.
+func (c *Collection) items(tx *Tx, rows []interface{}) []Item {
 	var items []Item
 	for _, row := range rows {
-		item, _, ok := row.get(tx)
-		if ok {
-			items = append(items, item)
+		item, cas, version, ok := row.(*Row).versioned(tx)
+		if !ok {
+			continue
+		}
+		if version < atomic.LoadInt32(&c.version) {
+			item, _ = c.upgrade(tx, row.(*Row), item, cas, version)
 		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// view is items for a caller holding the lock, the upgraded items are not stored.
+func (c *Collection) view(tx *Tx, rows []interface{}) []Item {
+	var items []Item
+	for _, row := range rows {
+		item, _, version, ok := row.(*Row).versioned(tx)
+		if !ok {
+			continue
+		}
+		if version < c.version {
+			if upgraded, err := c.migrate(item, version); err == nil {
+				item = upgraded
+			}
+		}
+		items = append(items, item)
 	}
 	return items
 }
 
 // Put ...
-func (c Collection) Put(tx *Tx, item Item, cas uint64) (uint64, bool) {
-	one := &Row{}
+func (c *Collection) Put(tx *Tx, item Item, cas uint64) (uint64, bool) {
+	cas, err := c.Store(tx, item, cas)
+	return cas, err == nil
+}
+
+// Store is Put which tells why the item was not stored. Rules, foreign keys and immutable
+// fields of the item are checked before any index is touched, immutable fields against the
+// item it replaces under the row lock.
+func (c *Collection) Store(tx *Tx, item Item, cas uint64) (uint64, error) {
+	if tx.closed() {
+		return 0, ErrClosed
+	}
+	defer c.rlock()()
+	return c.store(tx, item, cas)
+}
+
+func (c *Collection) store(tx *Tx, item Item, cas uint64) (uint64, error) {
+	if c.closed {
+		return 0, ErrClosed
+	}
+	if err := c.fault(); err != nil {
+		return 0, err
+	}
+	if err := c.validate(item); err != nil {
+		return 0, err
+	}
+	unlock, err := c.check(tx, item)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	cas, err = c.put(tx, item, cas, false)
+	if err := c.fault(); err != nil {
+		return 0, err
+	}
+	return cas, err
+}
+
+// put stores the item, with insert set it refuses to update a committed item.
+func (c *Collection) put(tx *Tx, item Item, cas uint64, insert bool) (uint64, error) {
+	one := &Row{pager: c.pager()}
 	one.lock(tx)
 	defer one.unlock(tx)
 	key := c.Indexes[0].Key(item)
@@ -75,39 +232,106 @@ index:
 	row, ok := c.Indexes[0].Put(key, one)
 	if ok {
 		if row.committed(tx) {
+			if insert {
+				return 0, ErrRejected
+			}
 			return c.update(tx, row, item, cas)
 		}
 		goto index
 	}
-	return c.insert(tx, row, item, cas, Rollback{index: c.Indexes[0], row: row, key: key})
+	return rejected(c.insert(tx, row, item, cas, Rollback{index: c.Indexes[0], row: row, key: key}))
 }
 
-func (c Collection) update(tx *Tx, row *Row, item Item, cas uint64) (uint64, bool) {
+// update replaces the item of the committed row, immutable fields and referenced keys of the
+// replaced item are checked under the row lock.
+func (c *Collection) update(tx *Tx, row *Row, item Item, cas uint64) (uint64, error) {
 	row.lock(tx)
 	defer row.unlock(tx)
+	if cas == 0 {
+		cas = row.cas + 1
+	} else if cas <= row.cas {
+		return 0, ErrRejected
+	}
+	return c.replace(tx, row, item, cas)
+}
+
+// replace is update for a caller holding the row lock, the item gets the cas.
+func (c *Collection) replace(tx *Tx, row *Row, item Item, cas uint64) (uint64, error) {
+	old := row.item()
+	if old == nil {
+		return 0, ErrNotFound
+	}
+	if err := c.unchanged(old, item); err != nil {
+		return 0, err
+	}
+	if err := c.keep(tx, old, item); err != nil {
+		return 0, err
+	}
+	if row.ord == 0 && c.bitmapped() {
+		c.ordinals.acquire(row)
+	}
 	var rollbacks, unleashes []Rollback
 	for _, index := range c.Indexes[1:] {
-		key := index.Key(item)
-	index:
-		one, ok := index.Put(key, row)
-		if ok {
-			if one != row {
-				if one.committed(tx) {
-					return c.rollback(rollbacks...)
+		if key, ok := index.key(item, row); ok {
+		index:
+			one, ok := index.Put(key, row)
+			if ok {
+				if one != row {
+					if one.committed(tx) {
+						return rejected(c.rollback(rollbacks...))
+					}
+					goto index
 				}
-				goto index
+				continue
 			}
-			continue
+			rollbacks = append(rollbacks, Rollback{index: index, row: row, key: key})
+		}
+		if key, ok := index.key(old, row); ok {
+			unleashes = append(unleashes, Rollback{index: index, row: row, key: key})
 		}
-		rollbacks = append(rollbacks, Rollback{index: index, row: row, key: key})
-		unleashes = append(unleashes, Rollback{index: index, row: row, key: index.Key(row)})
 	}
-	return c.end(rollbacks, row, item, cas, unleashes...)
+	for _, b := range c.building {
+		key, ok := b.key(item, row)
+		if ok {
+			if !b.put(tx, key, row) {
+				continue
+			}
+			rollbacks = append(rollbacks, Rollback{index: b.Index, row: row, key: key})
+		}
+		if key, ok := b.key(old, row); ok {
+			unleashes = append(unleashes, Rollback{index: b.Index, row: row, key: key})
+		}
+	}
+	return rejected(c.end(rollbacks, row, item, cas, unleashes...))
+}
+
+func rejected(cas uint64, ok bool) (uint64, error) {
+	if !ok {
+		return 0, ErrRejected
+	}
+	return cas, nil
+}
+
+func (c *Collection) insert(tx *Tx, row *Row, item Item, cas uint64, rollbacks ...Rollback) (uint64, bool) {
+	if cas == 0 {
+		cas = 1
+	}
+	if c.bitmapped() {
+		c.ordinals.acquire(row)
+	}
+	cas, ok := c.link(tx, row, item, cas, rollbacks...)
+	if !ok {
+		c.ordinals.release(row)
+	}
+	return cas, ok
 }
 
-func (c Collection) insert(tx *Tx, row *Row, item Item, cas uint64, rollbacks ...Rollback) (uint64, bool) {
+func (c *Collection) link(tx *Tx, row *Row, item Item, cas uint64, rollbacks ...Rollback) (uint64, bool) {
 	for _, index := range c.Indexes[1:] {
-		key := index.Key(item)
+		key, ok := index.key(item, row)
+		if !ok {
+			continue
+		}
 	index:
 		one, ok := index.Put(key, row)
 		if ok {
@@ -121,33 +345,44 @@ func (c Collection) insert(tx *Tx, row *Row, item Item, cas uint64, rollbacks ..
 		}
 		rollbacks = append(rollbacks, Rollback{index: index, row: row, key: key})
 	}
+	for _, b := range c.building {
+		key, ok := b.key(item, row)
+		if ok && b.put(tx, key, row) {
+			rollbacks = append(rollbacks, Rollback{index: b.Index, row: row, key: key})
+		}
+	}
 	return c.end(rollbacks, row, item, cas)
 }
 
-func (c Collection) rollback(rollbacks ...Rollback) (uint64, bool) {
+func (c *Collection) rollback(rollbacks ...Rollback) (uint64, bool) {
 	for _, r := range rollbacks {
-		r.index.LoadAndDelete(r.key, r.row)
+		r.index.Pop(r.key, r.row)
 	}
 	return 0, false
 }
 
-func (c Collection) commit(row *Row, item Item, cas uint64, rollbacks ...Rollback) (uint64, bool) {
-	if cas == 0 {
-		cas = row.cas + 1
-	} else if cas <= row.cas {
-		return 0, false
-	}
-	item, ok := item.Copy(row.Item)
+func (c *Collection) commit(row *Row, item Item, cas uint64, rollbacks ...Rollback) (uint64, bool) {
+	item, ok := item.Copy(row.item())
 	if !ok {
 		return 0, false
 	}
+	if row.pager != nil {
+		if row.pager.save(row, item) != nil {
+			return 0, false
+		}
+		item = nil
+	}
 	c.rollback(rollbacks...)
+	if row.cas > 0 && row.version < c.version {
+		atomic.AddInt64(&c.pending, -1)
+	}
 	row.Item = item
 	row.cas = cas
+	row.version = c.version
 	return cas, true
 }
 
-func (c Collection) end(rollbacks []Rollback, row *Row, item Item, cas uint64, unleashes ...Rollback) (uint64, bool) {
+func (c *Collection) end(rollbacks []Rollback, row *Row, item Item, cas uint64, unleashes ...Rollback) (uint64, bool) {
 	cas, ok := c.commit(row, item, cas, unleashes...)
 	if !ok {
 		return c.rollback(rollbacks...)
@@ -9,12 +9,12 @@ import (
 )
 
 type X1 struct {
-	ID   uuid.UUID `json:"id"`
-	Type string    `json:"type"`
-	Name int       `json:"name"`
-	Code int       `json:"code"`
+	ID   uuid.UUID `json:"id" memdb:"pk"`
+	Type string    `json:"type" memdb:"unique,group=type_name"`
+	Name int       `json:"name" memdb:"unique,group=type_name"`
+	Code int       `json:"code" memdb:"unique"`
 	Ages []int     `json:"ages"`
-	Time time.Time `json:"time"`
+	Time time.Time `json:"time" memdb:"index"`
 
 	F func(string) bool
 }
@@ -52,9 +52,9 @@ func (x X1) Field(name string) interface{} {
 	}
 }
 
-func newCollection(t testing.TB, items ...Item) Collection {
+func newCollection(t testing.TB, items ...Item) *Collection {
 	t.Helper()
-	collection := Collection{
+	collection := &Collection{
 		Indexes: []Index{
 			{
 				Field:   []string{"id"},
@@ -81,9 +81,16 @@ func newCollection(t testing.TB, items ...Item) Collection {
 	return collection
 }
 
-func printCollection(t testing.TB, collection Collection) {
+// newIndexed returns a collection with the primary index on "id" followed by the indexes.
+func newIndexed(indexes ...Index) *Collection {
+	return &Collection{
+		Indexes: append([]Index{{Field: []string{"id"}, Mapper: &UniqueIndex{}, Indexer: Format}}, indexes...),
+	}
+}
+
+func printCollection(t testing.TB, collection *Collection) {
 	t.Helper()
-	for _, i := range collection.Indexes {
+	for _, i := range collection.Indices() {
 		t.Log(i.Field)
 		i.Range(func(key, value interface{}) bool {
 			t.Log(key, value)
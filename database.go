@@ -0,0 +1,269 @@
+package memdb
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	ErrClosed     = errors.New("memdb: closed")
+	ErrExists     = errors.New("memdb: collection already exists")
+	ErrCollection = errors.New("memdb: no such collection")
+)
+
+// Database groups named collections. A transaction from Begin may be used with any of them.
+type Database struct {
+	mx          sync.RWMutex
+	collections map[string]*Collection
+	sequences   map[string]*Sequence
+	closed      bool
+}
+
+// Register adds the collection under name.
+func (d *Database) Register(name string, c *Collection) error {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	if d.closed {
+		return ErrClosed
+	}
+	if _, ok := d.collections[name]; ok {
+		return ErrExists
+	}
+	if d.collections == nil {
+		d.collections = make(map[string]*Collection)
+	}
+	d.collections[name] = c
+	return nil
+}
+
+// Drop removes the collection registered under name, the collection itself stays usable.
+func (d *Database) Drop(name string) error {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	if d.closed {
+		return ErrClosed
+	}
+	if _, ok := d.collections[name]; !ok {
+		return ErrCollection
+	}
+	delete(d.collections, name)
+	return nil
+}
+
+// Collection returns the collection registered under name or nil.
+func (d *Database) Collection(name string) *Collection {
+	d.mx.RLock()
+	defer d.mx.RUnlock()
+	return d.collections[name]
+}
+
+// Names returns the names of the collections in order.
+func (d *Database) Names() []string {
+	d.mx.RLock()
+	defer d.mx.RUnlock()
+	names := make([]string, 0, len(d.collections))
+	for name := range d.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Begin returns a transaction of the database, writes with it are refused with ErrClosed once
+// the database is closed, also to collections dropped from it.
+func (d *Database) Begin() (*Tx, error) {
+	d.mx.RLock()
+	defer d.mx.RUnlock()
+	if d.closed {
+		return nil, ErrClosed
+	}
+	return &Tx{db: d}, nil
+}
+
+// closed tells if the transaction is of a closed database, it is checked before a write takes
+// any collection lock since Close waits for running writes.
+func (t *Tx) closed() bool {
+	if t.db == nil {
+		return false
+	}
+	t.db.mx.RLock()
+	defer t.db.mx.RUnlock()
+	return t.db.closed
+}
+
+// Snapshot returns the committed items of every collection. Writers of all collections are
+// blocked while it is taken, so the items are consistent across collections.
+func (d *Database) Snapshot(tx *Tx) (map[string][]Item, error) {
+	d.mx.RLock()
+	defer d.mx.RUnlock()
+	if d.closed {
+		return nil, ErrClosed
+	}
+	collections := make([]*Collection, 0, len(d.collections))
+	for _, c := range d.collections {
+		collections = append(collections, c)
+	}
+	defer lock(collections...)()
+	snapshot := make(map[string][]Item, len(d.collections))
+	for name, c := range d.collections {
+		snapshot[name] = c.all(tx)
+	}
+	return snapshot, nil
+}
+
+var ranks uint64
+
+// rank orders the collections for locking several of them, it's given on first use.
+func (c *Collection) rank() uint64 {
+	if r := atomic.LoadUint64(&c.order); r != 0 {
+		return r
+	}
+	atomic.CompareAndSwapUint64(&c.order, 0, atomic.AddUint64(&ranks, 1))
+	return atomic.LoadUint64(&c.order)
+}
+
+// lock takes the write locks of the collections in rank order, each collection once, and
+// returns the function releasing them. Code locking several collections uses this order.
+func lock(collections ...*Collection) func() {
+	sorted := distinct(collections)
+	for _, c := range sorted {
+		c.mx.Lock()
+	}
+	return func() {
+		for n := len(sorted) - 1; n >= 0; n-- {
+			sorted[n].mx.Unlock()
+		}
+	}
+}
+
+// rlock takes the read locks of the collection and of the collections linked to it by foreign
+// keys in rank order, and returns the function releasing them. Writes follow the foreign keys
+// into the linked collections, so their locks are taken up front in the order of lock.
+func (c *Collection) rlock() func() {
+	c.mx.RLock()
+	if len(c.foreign) == 0 && len(c.referrer) == 0 {
+		return c.mx.RUnlock
+	}
+	c.mx.RUnlock()
+	for {
+		linked := []*Collection{c}
+		for n := 0; n < len(linked); n++ {
+			linked[n].mx.RLock()
+			linked = appendNew(linked, linked[n].linked()...)
+			linked[n].mx.RUnlock()
+		}
+		sorted := distinct(linked)
+		for _, c := range sorted {
+			c.mx.RLock()
+		}
+		unlock := func() {
+			for n := len(sorted) - 1; n >= 0; n-- {
+				sorted[n].mx.RUnlock()
+			}
+		}
+		// Foreign keys are only added, so the linked collections are complete unless one
+		// of them got a foreign key to another collection meanwhile.
+		complete := true
+		for _, c := range sorted {
+			if len(appendNew(linked, c.linked()...)) != len(linked) {
+				complete = false
+			}
+		}
+		if complete {
+			return unlock
+		}
+		unlock()
+	}
+}
+
+// appendNew appends the collections which are not in collections yet.
+func appendNew(collections []*Collection, more ...*Collection) []*Collection {
+	for _, c := range more {
+		if !has(collections, c) {
+			collections = append(collections, c)
+		}
+	}
+	return collections
+}
+
+func has(collections []*Collection, c *Collection) bool {
+	for _, x := range collections {
+		if x == c {
+			return true
+		}
+	}
+	return false
+}
+
+// distinct returns the collections without repeats in rank order.
+func distinct(collections []*Collection) []*Collection {
+	seen := make(map[*Collection]bool, len(collections))
+	sorted := make([]*Collection, 0, len(collections))
+	for _, c := range collections {
+		if c != nil && !seen[c] {
+			seen[c] = true
+			sorted = append(sorted, c)
+		}
+	}
+	sort.Slice(sorted, func(a, b int) bool {
+		return sorted[a].rank() < sorted[b].rank()
+	})
+	return sorted
+}
+
+// Stats returns the statistics of every index of every collection.
+func (d *Database) Stats() map[string][]Stats {
+	d.mx.RLock()
+	defer d.mx.RUnlock()
+	stats := make(map[string][]Stats, len(d.collections))
+	for name, c := range d.collections {
+		stats[name] = c.Stats()
+	}
+	return stats
+}
+
+// Close waits for running writes, then refuses further writes to the collections and closes
+// the mappers implementing io.Closer. It returns the first error of the mappers.
+func (d *Database) Close() error {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	if d.closed {
+		return ErrClosed
+	}
+	d.closed = true
+	var err error
+	for _, c := range d.collections {
+		if e := c.close(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// all returns the committed items of the collection, the caller holds the lock.
+func (c *Collection) all(tx *Tx) []Item {
+	var rows []interface{}
+	c.Indexes[0].Range(func(_, value interface{}) bool {
+		rows = append(rows, value)
+		return true
+	})
+	return c.view(tx, rows)
+}
+
+func (c *Collection) close() error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.closed = true
+	var err error
+	for _, index := range c.Indexes {
+		if closer, ok := index.Mapper.(io.Closer); ok {
+			if e := closer.Close(); err == nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
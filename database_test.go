@@ -0,0 +1,57 @@
+package memdb
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatabase(t *testing.T) {
+	var db Database
+	customers, orders := newCollection(t), newCollection(t)
+	disk := &DiskIndex{Unique: true}
+	orders.Indexes[1].Mapper = disk
+	require.NoError(t, db.Register("customers", customers))
+	require.NoError(t, db.Register("orders", orders))
+	require.ErrorIs(t, db.Register("orders", orders), ErrExists)
+	require.NoError(t, db.Register("sales", orders))
+	require.Equal(t, []string{"customers", "orders", "sales"}, db.Names())
+	require.Same(t, orders, db.Collection("orders"))
+	require.Nil(t, db.Collection("items"))
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	customer := X1{ID: uuid.New(), Type: "customer", Code: 1}
+	order := X1{ID: uuid.New(), Type: "order", Code: 1}
+	_, ok := db.Collection("customers").Put(tx, customer, 0)
+	require.True(t, ok)
+	_, ok = db.Collection("orders").Put(tx, order, 0)
+	require.True(t, ok)
+
+	snapshot, err := db.Snapshot(tx)
+	require.NoError(t, err)
+	require.Equal(t, map[string][]Item{"customers": {customer}, "orders": {order}, "sales": {order}}, snapshot)
+	require.Equal(t, int64(1), db.Stats()["orders"][0].Rows)
+
+	require.NoError(t, db.Drop("customers"))
+	require.ErrorIs(t, db.Drop("customers"), ErrCollection)
+	require.NoError(t, db.Close())
+	require.ErrorIs(t, db.Close(), ErrClosed)
+	_, err = db.Begin()
+	require.ErrorIs(t, err, ErrClosed)
+	_, err = db.Snapshot(tx)
+	require.ErrorIs(t, err, ErrClosed)
+	_, ok = orders.Put(tx, X1{ID: uuid.New()}, 0)
+	require.False(t, ok)
+	_, ok = orders.Delete(tx, order, 0)
+	require.False(t, ok)
+	_, err = orders.AddIndex(tx, Index{Field: []string{"code"}, Mapper: &UniqueIndex{}, Indexer: Format})
+	require.ErrorIs(t, err, ErrClosed)
+	_, ok = disk.Load(orders.Indexes[1].Key(order))
+	require.False(t, ok)
+	_, err = customers.Store(tx, X1{ID: uuid.New()}, 0)
+	require.ErrorIs(t, err, ErrClosed)
+	_, ok = customers.Put(&Tx{}, X1{ID: uuid.New()}, 0)
+	require.True(t, ok)
+}
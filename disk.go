@@ -0,0 +1,728 @@
+package memdb
+
+import (
+	"container/list"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var ErrDisk = errors.New("memdb: disk index failed")
+
+// Codec encodes the items of a collection for a DiskIndex.
+type Codec interface {
+	Encode(Item) ([]byte, error)
+	Decode([]byte) (Item, error)
+}
+
+// DiskIndex keeps its keys in a B+tree of pages in the file at Path, or in a temporary file when
+// Path is empty, and caches up to Pool pages in memory. Keys too long for a page continue in
+// overflow pages. With Unique set a leaf entry holds the one row of its key, otherwise the rows
+// of a key are kept as consecutive entries. Pages are not merged when keys are deleted.
+//
+// As the primary index of a collection with a Codec it also stores the items of the rows in the
+// file, and keeps up to Cache of them decoded in memory, so only row headers stay in memory.
+//
+// The file is scratch space which is closed by Close, an existing file at Path must be empty.
+// A failing read or write of the file fails the index: Err returns the error from then on, and
+// Store, Remove and Insert of its collection return it.
+type DiskIndex struct {
+	Path     string
+	PageSize int
+	Pool     int
+	Unique   bool
+	Codec    Codec
+	Cache    int
+	mx       sync.Mutex
+	err      error
+	file     *os.File
+	root     uint32
+	pages    uint32
+	free     []uint32
+	cache    map[uint32]*page
+	lru      list.List
+	handles  map[uint64]interface{}
+	ids      map[interface{}]uint64
+	refs     map[uint64]int
+	last     uint64
+	items    map[*Row]*list.Element
+	recent   list.List
+	counter
+}
+
+type cached struct {
+	row  *Row
+	item Item
+}
+
+type page struct {
+	id       uint32
+	leaf     bool
+	entries  []entry
+	children []uint32
+	next     uint32
+	overflow []uint32
+	dirty    bool
+	elem     *list.Element
+}
+
+// entry is a key and the handle of one of its values, the tree is ordered by both.
+type entry struct {
+	key    string
+	handle uint64
+}
+
+func (e entry) less(o entry) bool {
+	return e.key < o.key || e.key == o.key && e.handle < o.handle
+}
+
+const (
+	pageHeader     = 7
+	overflowHeader = 6
+	diskBatch      = 64
+)
+
+func (i *DiskIndex) init() {
+	if i.file != nil {
+		return
+	}
+	if i.PageSize < 256 || i.PageSize > 1<<16 {
+		i.PageSize = 4096
+	}
+	if i.Pool < 1 {
+		i.Pool = 256
+	}
+	if i.Cache < 1 {
+		i.Cache = 1024
+	}
+	var file *os.File
+	var err error
+	if i.Path == "" {
+		file, err = os.CreateTemp("", "memdb-*.idx")
+	} else {
+		file, err = os.OpenFile(i.Path, os.O_RDWR|os.O_CREATE, 0600)
+	}
+	check(err)
+	info, err := file.Stat()
+	if err == nil && info.Size() > 0 {
+		err = fmt.Errorf("%s is not empty", i.Path)
+	}
+	if err != nil {
+		_ = file.Close()
+		check(err)
+	}
+	i.file = file
+	i.cache = make(map[uint32]*page)
+	i.handles = make(map[uint64]interface{})
+	i.ids = make(map[interface{}]uint64)
+	i.refs = make(map[uint64]int)
+	i.items = make(map[*Row]*list.Element)
+	i.root = i.node(true).id
+}
+
+// Close closes and, when it is temporary, removes the file. The index is empty afterwards.
+func (i *DiskIndex) Close() error {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	if i.file == nil {
+		return nil
+	}
+	err := i.file.Close()
+	if i.Path == "" {
+		if e := os.Remove(i.file.Name()); err == nil {
+			err = e
+		}
+	}
+	i.file, i.root, i.pages, i.free, i.cache = nil, 0, 0, nil, nil
+	i.handles, i.ids, i.refs, i.last, i.items = nil, nil, nil, 0, nil
+	i.lru.Init()
+	i.recent.Init()
+	i.counter = counter{}
+	return err
+}
+
+// Err returns the error which failed the index.
+func (i *DiskIndex) Err() error {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	return i.err
+}
+
+// failure is a failing read or write of the file, check panics with it and guard recovers it.
+type failure struct {
+	error
+}
+
+func check(err error) {
+	if err != nil {
+		panic(failure{err})
+	}
+}
+
+// guard makes a failure of the file the error of the index, the caller holds the lock.
+func (i *DiskIndex) guard() {
+	i.fail(recover())
+}
+
+func (i *DiskIndex) fail(r interface{}) {
+	if r != nil {
+		f, ok := r.(failure)
+		if !ok {
+			panic(r)
+		}
+		i.err = fmt.Errorf("%w: %v", ErrDisk, f.error)
+	}
+}
+
+// fault returns the error of a failed mapper of the collection, the caller holds the lock.
+func (c *Collection) fault() error {
+	for _, index := range c.Indexes {
+		if m, ok := index.Mapper.(interface{ Err() error }); ok {
+			if err := m.Err(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Collection) pager() pager {
+	if i, ok := c.Indexes[0].Mapper.(*DiskIndex); ok && i.Codec != nil {
+		return i
+	}
+	return nil
+}
+
+// inline is the number of key bytes stored in a page, so that every entry fits a quarter page.
+func (i *DiskIndex) inline() int {
+	return i.PageSize/4 - 26
+}
+
+func (i *DiskIndex) entrySize(e entry, leaf bool) int {
+	n := len(e.key)
+	if n > i.inline() {
+		n = i.inline() + 4
+	}
+	n += 8 + uvarintSize(uint64(len(e.key)))
+	if !leaf {
+		n += 4
+	}
+	return n
+}
+
+func (i *DiskIndex) size(p *page) int {
+	n := pageHeader
+	if !p.leaf {
+		n += 4
+	}
+	for _, e := range p.entries {
+		n += i.entrySize(e, p.leaf)
+	}
+	return n
+}
+
+func uvarintSize(x uint64) int {
+	var buf [binary.MaxVarintLen64]byte
+	return binary.PutUvarint(buf[:], x)
+}
+
+func (i *DiskIndex) alloc() uint32 {
+	if n := len(i.free); n > 0 {
+		id := i.free[n-1]
+		i.free = i.free[:n-1]
+		return id
+	}
+	i.pages++
+	return i.pages
+}
+
+func (i *DiskIndex) node(leaf bool) *page {
+	p := &page{id: i.alloc(), leaf: leaf, dirty: true}
+	i.cache[p.id] = p
+	p.elem = i.lru.PushFront(p)
+	return p
+}
+
+func (i *DiskIndex) get(id uint32) *page {
+	if p, ok := i.cache[id]; ok {
+		i.lru.MoveToFront(p.elem)
+		return p
+	}
+	p := i.decode(id, i.read(id))
+	i.cache[id] = p
+	p.elem = i.lru.PushFront(p)
+	return p
+}
+
+func (i *DiskIndex) read(id uint32) []byte {
+	buf := make([]byte, i.PageSize)
+	_, err := i.file.ReadAt(buf, int64(id-1)*int64(i.PageSize))
+	check(err)
+	return buf
+}
+
+func (i *DiskIndex) write(id uint32, buf []byte) {
+	_, err := i.file.WriteAt(buf, int64(id-1)*int64(i.PageSize))
+	check(err)
+}
+
+// shrink evicts the least recently used pages over the pool size. Pages are only evicted
+// between operations, so an operation may keep the pages it works on.
+func (i *DiskIndex) shrink() {
+	for i.lru.Len() > i.Pool {
+		p := i.lru.Remove(i.lru.Back()).(*page)
+		delete(i.cache, p.id)
+		if p.dirty {
+			i.flush(p)
+		}
+	}
+}
+
+func (i *DiskIndex) flush(p *page) {
+	i.free = append(i.free, p.overflow...)
+	p.overflow = nil
+	buf := make([]byte, i.PageSize)
+	if p.leaf {
+		buf[0] = 1
+	}
+	binary.LittleEndian.PutUint16(buf[1:], uint16(len(p.entries)))
+	binary.LittleEndian.PutUint32(buf[3:], p.next)
+	off := pageHeader
+	if !p.leaf {
+		binary.LittleEndian.PutUint32(buf[off:], p.children[0])
+		off += 4
+	}
+	for n, e := range p.entries {
+		off += binary.PutUvarint(buf[off:], uint64(len(e.key)))
+		if len(e.key) > i.inline() {
+			off += copy(buf[off:], e.key[:i.inline()])
+			binary.LittleEndian.PutUint32(buf[off:], i.spill(p, e.key[i.inline():]))
+			off += 4
+		} else {
+			off += copy(buf[off:], e.key)
+		}
+		binary.LittleEndian.PutUint64(buf[off:], e.handle)
+		off += 8
+		if !p.leaf {
+			binary.LittleEndian.PutUint32(buf[off:], p.children[n+1])
+			off += 4
+		}
+	}
+	i.write(p.id, buf)
+	p.dirty = false
+}
+
+// spill writes the rest of a long key to a chain of overflow pages and returns its head.
+func (i *DiskIndex) spill(p *page, s string) uint32 {
+	ids := i.chain(s)
+	p.overflow = append(p.overflow, ids...)
+	return ids[0]
+}
+
+// chain writes s to a chain of overflow pages and returns their ids.
+func (i *DiskIndex) chain(s string) []uint32 {
+	room := i.PageSize - overflowHeader
+	ids := make([]uint32, (len(s)+room-1)/room)
+	if len(ids) == 0 {
+		ids = make([]uint32, 1)
+	}
+	for n := range ids {
+		ids[n] = i.alloc()
+	}
+	for n, id := range ids {
+		buf := make([]byte, i.PageSize)
+		if n+1 < len(ids) {
+			binary.LittleEndian.PutUint32(buf, ids[n+1])
+		}
+		chunk := s[n*room:]
+		if len(chunk) > room {
+			chunk = chunk[:room]
+		}
+		binary.LittleEndian.PutUint16(buf[4:], uint16(len(chunk)))
+		copy(buf[overflowHeader:], chunk)
+		i.write(id, buf)
+	}
+	return ids
+}
+
+// follow reads the chain of overflow pages from next on and returns their ids.
+func (i *DiskIndex) follow(b *strings.Builder, next uint32) (ids []uint32) {
+	for next != 0 {
+		ids = append(ids, next)
+		chunk := i.read(next)
+		b.Write(chunk[overflowHeader : overflowHeader+int(binary.LittleEndian.Uint16(chunk[4:]))])
+		next = binary.LittleEndian.Uint32(chunk)
+	}
+	return
+}
+
+func (i *DiskIndex) decode(id uint32, buf []byte) *page {
+	p := &page{id: id, leaf: buf[0] == 1}
+	count := int(binary.LittleEndian.Uint16(buf[1:]))
+	p.next = binary.LittleEndian.Uint32(buf[3:])
+	off := pageHeader
+	if !p.leaf {
+		p.children = append(p.children, binary.LittleEndian.Uint32(buf[off:]))
+		off += 4
+	}
+	for n := 0; n < count; n++ {
+		size, k := binary.Uvarint(buf[off:])
+		off += k
+		var key strings.Builder
+		if int(size) > i.inline() {
+			key.Write(buf[off : off+i.inline()])
+			off += i.inline()
+			p.overflow = append(p.overflow, i.follow(&key, binary.LittleEndian.Uint32(buf[off:]))...)
+			off += 4
+		} else {
+			key.Write(buf[off : off+int(size)])
+			off += int(size)
+		}
+		p.entries = append(p.entries, entry{key: key.String(), handle: binary.LittleEndian.Uint64(buf[off:])})
+		off += 8
+		if !p.leaf {
+			p.children = append(p.children, binary.LittleEndian.Uint32(buf[off:]))
+			off += 4
+		}
+	}
+	return p
+}
+
+// search returns the position of the first entry of the page not less than e.
+func search(p *page, e entry) int {
+	return sort.Search(len(p.entries), func(n int) bool {
+		return !p.entries[n].less(e)
+	})
+}
+
+// child returns the position of the child of an inner page which holds e.
+func child(p *page, e entry) int {
+	return sort.Search(len(p.entries), func(n int) bool {
+		return e.less(p.entries[n])
+	})
+}
+
+func (i *DiskIndex) leaf(e entry) *page {
+	p := i.get(i.root)
+	for !p.leaf {
+		p = i.get(p.children[child(p, e)])
+	}
+	return p
+}
+
+func (i *DiskIndex) insert(e entry) {
+	if sep, right := i.put(i.root, e); right != 0 {
+		root := i.node(false)
+		root.entries = []entry{sep}
+		root.children = []uint32{i.root, right}
+		i.root = root.id
+	}
+}
+
+// put adds e under the page id and returns the separator and the new right page of a split.
+func (i *DiskIndex) put(id uint32, e entry) (entry, uint32) {
+	p := i.get(id)
+	if p.leaf {
+		n := search(p, e)
+		p.entries = append(p.entries, entry{})
+		copy(p.entries[n+1:], p.entries[n:])
+		p.entries[n] = e
+	} else {
+		n := child(p, e)
+		sep, right := i.put(p.children[n], e)
+		if right == 0 {
+			return entry{}, 0
+		}
+		p.entries = append(p.entries, entry{})
+		copy(p.entries[n+1:], p.entries[n:])
+		p.entries[n] = sep
+		p.children = append(p.children, 0)
+		copy(p.children[n+2:], p.children[n+1:])
+		p.children[n+1] = right
+	}
+	p.dirty = true
+	if i.size(p) <= i.PageSize {
+		return entry{}, 0
+	}
+	return i.split(p)
+}
+
+func (i *DiskIndex) split(p *page) (entry, uint32) {
+	half, size, mid := i.size(p)/2, 0, 0
+	for mid < len(p.entries)-1 && size < half {
+		size += i.entrySize(p.entries[mid], p.leaf)
+		mid++
+	}
+	q := i.node(p.leaf)
+	if p.leaf {
+		q.entries = append([]entry(nil), p.entries[mid:]...)
+		p.entries = p.entries[:mid:mid]
+		q.next, p.next = p.next, q.id
+		return q.entries[0], q.id
+	}
+	sep := p.entries[mid]
+	q.entries = append([]entry(nil), p.entries[mid+1:]...)
+	q.children = append([]uint32(nil), p.children[mid+1:]...)
+	p.entries = p.entries[:mid:mid]
+	p.children = p.children[: mid+1 : mid+1]
+	return sep, q.id
+}
+
+func (i *DiskIndex) delete(e entry) bool {
+	p := i.leaf(e)
+	n := search(p, e)
+	if n == len(p.entries) || p.entries[n] != e {
+		return false
+	}
+	p.entries = append(p.entries[:n], p.entries[n+1:]...)
+	p.dirty = true
+	return true
+}
+
+// scan calls f for the entries from e on in order.
+func (i *DiskIndex) scan(e entry, f func(entry) bool) {
+	p := i.leaf(e)
+	for n := search(p, e); ; n++ {
+		for n == len(p.entries) {
+			if p.next == 0 {
+				return
+			}
+			p, n = i.get(p.next), 0
+		}
+		if !f(p.entries[n]) {
+			return
+		}
+	}
+}
+
+// iterate calls f for the values from e on in key order. The lock is released between
+// batches of entries, so f may use the index.
+func (i *DiskIndex) iterate(e entry, f func(key, value interface{}) bool) {
+	for {
+		entries, values := i.batch(e)
+		for n := range entries {
+			if !f(entries[n].key, values[n]) {
+				return
+			}
+		}
+		if len(entries) < diskBatch {
+			return
+		}
+		e = entries[len(entries)-1]
+		e.handle++
+	}
+}
+
+func (i *DiskIndex) batch(e entry) (entries []entry, values []interface{}) {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	if i.file == nil || i.err != nil {
+		return
+	}
+	defer i.guard()
+	defer i.shrink()
+	i.scan(e, func(e entry) bool {
+		entries = append(entries, e)
+		values = append(values, i.handles[e.handle])
+		return len(entries) < diskBatch
+	})
+	return
+}
+
+// count returns the number of values under key.
+func (i *DiskIndex) count(key string) (n int64) {
+	i.scan(entry{key: key}, func(e entry) bool {
+		if e.key != key {
+			return false
+		}
+		n++
+		return true
+	})
+	return
+}
+
+func (i *DiskIndex) Load(key interface{}) (values []interface{}, ok bool) {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	if i.file == nil || i.err != nil {
+		return nil, false
+	}
+	defer i.guard()
+	defer i.shrink()
+	i.scan(entry{key: key.(string)}, func(e entry) bool {
+		if e.key != key.(string) {
+			return false
+		}
+		values = append(values, i.handles[e.handle])
+		return true
+	})
+	return values, len(values) > 0
+}
+
+// LoadOrStore of a failed index stores nothing and returns value as if it was stored, the
+// collection returns the error of the index.
+func (i *DiskIndex) LoadOrStore(key, value interface{}) (actual interface{}, loaded bool) {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	if i.err != nil {
+		return value, false
+	}
+	defer func() {
+		if i.fail(recover()); i.err != nil {
+			actual, loaded = value, false
+		}
+	}()
+	i.init()
+	defer i.shrink()
+	h, ok := i.ids[value]
+	var one interface{}
+	var found bool
+	n := int64(1)
+	i.scan(entry{key: key.(string)}, func(e entry) bool {
+		if e.key != key.(string) {
+			return false
+		}
+		if i.Unique || ok && e.handle == h {
+			one, found = i.handles[e.handle], true
+			return false
+		}
+		n++
+		return true
+	})
+	if found {
+		return one, true
+	}
+	if !ok {
+		i.last++
+		h = i.last
+		i.ids[value], i.handles[h] = h, value
+	}
+	i.refs[h]++
+	i.insert(entry{key: key.(string), handle: h})
+	i.add(key, n)
+	return value, false
+}
+
+func (i *DiskIndex) LoadAndDelete(key, value interface{}) (interface{}, bool) {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	h, ok := i.ids[value]
+	if !ok || i.err != nil {
+		return nil, false
+	}
+	defer i.guard()
+	defer i.shrink()
+	if !i.delete(entry{key: key.(string), handle: h}) {
+		return nil, false
+	}
+	if i.refs[h]--; i.refs[h] == 0 {
+		delete(i.refs, h)
+		delete(i.ids, value)
+		delete(i.handles, h)
+	}
+	i.remove(key, i.count(key.(string)))
+	return value, true
+}
+
+func (i *DiskIndex) Range(f func(key, value interface{}) bool) {
+	i.iterate(entry{}, f)
+}
+
+// Scan calls f for every value with a key starting with prefix in key order.
+func (i *DiskIndex) Scan(prefix string, f func(key, value interface{}) bool) {
+	i.iterate(entry{key: prefix}, func(key, value interface{}) bool {
+		return strings.HasPrefix(key.(string), prefix) && f(key, value)
+	})
+}
+
+// Seek calls f for every value with a key following after in key order.
+func (i *DiskIndex) Seek(after string, f func(key, value interface{}) bool) {
+	e := entry{key: after, handle: math.MaxUint64}
+	if after == "" {
+		e.handle = 0
+	}
+	i.iterate(e, f)
+}
+
+func (i *DiskIndex) save(row *Row, item Item) (err error) {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	if i.err != nil {
+		return i.err
+	}
+	defer func() {
+		if i.fail(recover()); i.err != nil {
+			err = i.err
+		}
+	}()
+	data, err := i.Codec.Encode(item)
+	if err != nil {
+		return fmt.Errorf("memdb: encode %T: %w", item, err)
+	}
+	i.init()
+	page := i.chain(string(data))[0]
+	i.release(row)
+	row.page = page
+	i.remember(row, item)
+	return nil
+}
+
+func (i *DiskIndex) load(row *Row) (item Item, err error) {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	if e, ok := i.items[row]; ok {
+		i.recent.MoveToFront(e)
+		return e.Value.(*cached).item, nil
+	}
+	if i.err != nil || i.file == nil {
+		return nil, ErrDisk
+	}
+	defer func() {
+		if i.fail(recover()); i.err != nil {
+			item, err = nil, i.err
+		}
+	}()
+	var b strings.Builder
+	i.follow(&b, row.page)
+	if item, err = i.Codec.Decode([]byte(b.String())); err != nil {
+		return nil, fmt.Errorf("memdb: decode: %w", err)
+	}
+	i.remember(row, item)
+	return item, nil
+}
+
+func (i *DiskIndex) drop(row *Row) {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	if i.err != nil || i.file == nil {
+		return
+	}
+	defer i.guard()
+	i.release(row)
+	row.page = 0
+}
+
+// release frees the pages of the item of the row and forgets it.
+func (i *DiskIndex) release(row *Row) {
+	if e, ok := i.items[row]; ok {
+		i.recent.Remove(e)
+		delete(i.items, row)
+	}
+	if row.page != 0 {
+		var b strings.Builder
+		i.free = append(i.free, i.follow(&b, row.page)...)
+	}
+}
+
+// remember caches the item of the row, forgetting the least recently used over Cache.
+func (i *DiskIndex) remember(row *Row, item Item) {
+	i.items[row] = i.recent.PushFront(&cached{row: row, item: item})
+	for i.recent.Len() > i.Cache {
+		delete(i.items, i.recent.Remove(i.recent.Back()).(*cached).row)
+	}
+}
@@ -0,0 +1,152 @@
+package memdb
+
+import (
+	"bytes"
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskIndex(t *testing.T) {
+	primary := &DiskIndex{Unique: true, PageSize: 256, Pool: 4}
+	codes := &DiskIndex{Unique: true, Path: filepath.Join(t.TempDir(), "code.idx"), PageSize: 256, Pool: 4}
+	types := &DiskIndex{PageSize: 256, Pool: 4}
+	defer primary.Close()
+	defer codes.Close()
+	defer types.Close()
+	collection := &Collection{
+		Indexes: []Index{
+			{
+				Field:   []string{"id"},
+				Mapper:  primary,
+				Indexer: Format,
+			}, {
+				Field:   []string{"code"},
+				Mapper:  codes,
+				Indexer: Ordered,
+			}, {
+				Field:   []string{"type"},
+				Mapper:  types,
+				Indexer: Format,
+			},
+		},
+	}
+	var items []Item
+	for i := 0; i < 1000; i++ {
+		item := X1{ID: uuid.New(), Type: strings.Repeat(strconv.Itoa(i%10), 300), Code: i}
+		_, ok := collection.Put(&Tx{}, item, 0)
+		require.True(t, ok)
+		items = append(items, item)
+	}
+	_, ok := collection.Put(&Tx{}, X1{ID: uuid.New(), Code: 1}, 0)
+	require.False(t, ok)
+	require.Equal(t, []Item{items[5]}, collection.Get(&Tx{}, 1, []interface{}{5}))
+	require.Equal(t, []Item{items[7]}, collection.Get(&Tx{}, 0, []interface{}{items[7].(X1).ID}))
+	require.Len(t, collection.Get(&Tx{}, 2, []interface{}{strings.Repeat("5", 300)}), 100)
+
+	page, cursor := collection.Page(&Tx{}, 1, "", 10)
+	require.Equal(t, items[:10], page)
+	page, _ = collection.Page(&Tx{}, 1, cursor, 10)
+	require.Equal(t, items[10:20], page)
+
+	for _, item := range items[:500] {
+		_, ok := collection.Delete(&Tx{}, item, 0)
+		require.True(t, ok)
+	}
+	require.Len(t, collection.Get(&Tx{}, 2, []interface{}{strings.Repeat("5", 300)}), 50)
+	require.Equal(t, []Stats{
+		{Keys: 500, Rows: 500, Max: 1, Bytes: collection.Stats()[0].Bytes},
+		{Keys: 500, Rows: 500, Max: 1, Bytes: collection.Stats()[1].Bytes},
+		{Keys: 10, Rows: 500, Max: 50, Bytes: collection.Stats()[2].Bytes},
+	}, collection.Stats())
+	require.Empty(t, collection.Verify(&Tx{}, false))
+
+	info, err := os.Stat(codes.Path)
+	require.NoError(t, err)
+	require.NotZero(t, info.Size())
+	require.NoError(t, codes.Close())
+	_, ok = codes.Load(Ordered(700))
+	require.False(t, ok)
+}
+
+type gobX1 struct{}
+
+func (gobX1) Encode(item Item) ([]byte, error) {
+	var b bytes.Buffer
+	err := gob.NewEncoder(&b).Encode(item.(X1))
+	return b.Bytes(), err
+}
+
+func (gobX1) Decode(data []byte) (Item, error) {
+	var x X1
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&x)
+	return x, err
+}
+
+func TestDiskIndex_rows(t *testing.T) {
+	primary := &DiskIndex{Unique: true, PageSize: 256, Pool: 4, Codec: gobX1{}, Cache: 8}
+	defer primary.Close()
+	collection := &Collection{
+		Indexes: []Index{
+			{
+				Field:   []string{"id"},
+				Mapper:  primary,
+				Indexer: Format,
+			}, {
+				Field:   []string{"code"},
+				Mapper:  &UniqueIndex{},
+				Indexer: Format,
+			},
+		},
+	}
+	var items []Item
+	for i := 0; i < 200; i++ {
+		item := X1{ID: uuid.New(), Type: strings.Repeat("x", i), Code: i}
+		_, err := collection.Store(&Tx{}, item, 0)
+		require.NoError(t, err)
+		items = append(items, item)
+	}
+	for _, row := range collection.Indexes[0].Get(collection.Indexes[0].Key(items[7])) {
+		require.Nil(t, row.Item)
+	}
+	require.LessOrEqual(t, len(primary.items), 8)
+	for n, item := range items {
+		require.Equal(t, []Item{item}, collection.Get(&Tx{}, 1, []interface{}{n}))
+	}
+	items[3] = X1{ID: items[3].(X1).ID, Type: "changed", Code: 1000}
+	_, err := collection.Store(&Tx{}, items[3], 0)
+	require.NoError(t, err)
+	require.Equal(t, []Item{items[3]}, collection.Get(&Tx{}, 0, []interface{}{items[3].(X1).ID}))
+	require.Empty(t, collection.Get(&Tx{}, 1, []interface{}{3}))
+	for _, item := range items[100:] {
+		_, err := collection.Remove(&Tx{}, item, 0)
+		require.NoError(t, err)
+	}
+	require.Empty(t, collection.Verify(&Tx{}, false))
+	require.Len(t, collection.Get(&Tx{}, 1, []interface{}{50}, []interface{}{150}), 1)
+	require.NotEmpty(t, primary.free)
+
+	require.NoError(t, primary.file.Close())
+	_, err = collection.Store(&Tx{}, X1{ID: uuid.New(), Code: 2000}, 0)
+	require.ErrorIs(t, err, ErrDisk)
+	require.ErrorIs(t, primary.Err(), ErrDisk)
+	_, err = collection.Remove(&Tx{}, items[0], 0)
+	require.ErrorIs(t, err, ErrDisk)
+
+	path := filepath.Join(t.TempDir(), "used.idx")
+	require.NoError(t, os.WriteFile(path, []byte("data"), 0600))
+	used := &DiskIndex{Path: path}
+	defer used.Close()
+	_, ok := used.LoadOrStore("key", &Row{})
+	require.False(t, ok)
+	require.ErrorIs(t, used.Err(), ErrDisk)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "data", string(data))
+}
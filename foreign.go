@@ -0,0 +1,326 @@
+package memdb
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var ErrForeignKey = errors.New("memdb: foreign key violation")
+
+// Action is what deleting a referenced item does to the items referencing it.
+type Action uint8
+
+const (
+	// Restrict refuses to delete an item while it is referenced.
+	Restrict Action = iota
+	// Cascade deletes the referencing items.
+	Cascade
+	// SetNull sets the referencing fields to nil, the referencing items must be Setters.
+	SetNull
+)
+
+// ForeignKey makes the Field of items of a collection refer to an item of the unique index
+// Index of the References collection. Items whose referencing fields are all nil refer to
+// nothing.
+type ForeignKey struct {
+	Name       string
+	Field      []string
+	References *Collection
+	Index      int
+	OnDelete   Action
+}
+
+// Setter is implemented by items which return a copy of themselves with a field changed.
+type Setter interface {
+	Set(field string, value interface{}) (Item, bool)
+}
+
+// ConstraintError is a write refused by the named constraint.
+type ConstraintError struct {
+	Constraint string
+	Reason     string
+	Err        error
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("%v: %s: %s", e.Err, e.Constraint, e.Reason)
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return e.Err
+}
+
+type referrer struct {
+	ForeignKey
+	child *Collection
+}
+
+// AddForeignKey declares the foreign key on the collection. Existing items are not checked.
+func (c *Collection) AddForeignKey(fk ForeignKey) error {
+	if fk.References == nil {
+		return fmt.Errorf("%w: foreign key %q references no collection", ErrSchema, fk.Name)
+	}
+	defer lock(c, fk.References)()
+	indexes := fk.References.Indexes
+	switch {
+	case fk.Index < 0 || fk.Index >= len(indexes):
+		return fmt.Errorf("%w: foreign key %q references index %d: %v", ErrSchema, fk.Name, fk.Index, ErrNoIndex)
+	case !isUnique(indexes[fk.Index].Mapper):
+		return fmt.Errorf("%w: foreign key %q references index %d which is not unique", ErrSchema, fk.Name, fk.Index)
+	case len(fk.Field) == 0 || len(fk.Field) != len(indexes[fk.Index].Field):
+		return fmt.Errorf("%w: foreign key %q has %d fields for index %d of %d", ErrSchema, fk.Name, len(fk.Field), fk.Index, len(indexes[fk.Index].Field))
+	}
+	for _, f := range c.foreign {
+		if f.Name == fk.Name {
+			return fmt.Errorf("%w: foreign key %q already exists", ErrSchema, fk.Name)
+		}
+	}
+	c.foreign = append(c.foreign, fk)
+	fk.References.referrer = append(fk.References.referrer, referrer{ForeignKey: fk, child: c})
+	return nil
+}
+
+func (fk ForeignKey) values(item Item) ([]interface{}, bool) {
+	var values []interface{}
+	var nils int
+	for _, f := range fk.Field {
+		value := item.Field(f)
+		if isNil(value) {
+			nils++
+		}
+		values = append(values, value)
+	}
+	return values, nils < len(values)
+}
+
+// linked returns the collections the collection reaches through foreign keys in either
+// direction, the caller holds its lock.
+func (c *Collection) linked() []*Collection {
+	var linked []*Collection
+	for _, fk := range c.foreign {
+		linked = append(linked, fk.References)
+	}
+	for _, r := range c.referrer {
+		linked = append(linked, r.child)
+	}
+	return linked
+}
+
+// check refuses an item referring to a missing item. The rows of the referenced items are read
+// locked until the returned function is called, so they are not deleted before the item is
+// stored. The caller holds the read locks of the linked collections.
+func (c *Collection) check(tx *Tx, item Item) (func(), error) {
+	type reference struct {
+		fk  ForeignKey
+		key string
+	}
+	var references []reference
+	for _, fk := range c.foreign {
+		values, ok := fk.values(item)
+		if !ok || fk.References == c && c.Indexes[fk.Index].Index(values...) == c.Indexes[fk.Index].Key(item) {
+			continue
+		}
+		references = append(references, reference{fk: fk, key: fk.References.Indexes[fk.Index].Index(values...)})
+	}
+	sort.Slice(references, func(a, b int) bool {
+		x, y := references[a], references[b]
+		if x.fk.References != y.fk.References {
+			return x.fk.References.rank() < y.fk.References.rank()
+		}
+		if x.fk.Index != y.fk.Index {
+			return x.fk.Index < y.fk.Index
+		}
+		return x.key < y.key
+	})
+	var locked []*Row
+	unlock := func() {
+		for n := len(locked) - 1; n >= 0; n-- {
+			locked[n].rw.RUnlock()
+		}
+	}
+	for _, r := range references {
+		var own string
+		if r.fk.References == c {
+			own = c.Indexes[0].Key(item)
+		}
+		row := r.fk.References.referenced(tx, r.fk.Index, r.key, own, locked)
+		if row == nil {
+			unlock()
+			return nil, &ConstraintError{Constraint: r.fk.Name, Reason: "referenced item does not exist", Err: ErrForeignKey}
+		}
+		if !locks(locked, row) {
+			locked = append(locked, row)
+		}
+	}
+	return unlock, nil
+}
+
+// referenced returns the row of the committed item with the key of index i read locked, or nil
+// when there is none. A row in locked is returned without locking it again. The row with the
+// primary key own is the one being written, its key is about to change, so it is never
+// returned.
+func (c *Collection) referenced(tx *Tx, i int, key string, own string, locked []*Row) *Row {
+	for _, row := range c.Indexes[i].Get(key) {
+		for {
+			item, cas, ok := row.get(tx)
+			if !ok || own != "" && c.Indexes[0].Key(item) == own {
+				break
+			}
+			if locks(locked, row) {
+				return row
+			}
+			row.rw.RLock()
+			if row.cas == cas {
+				return row
+			}
+			row.rw.RUnlock()
+		}
+	}
+	return nil
+}
+
+func locks(rows []*Row, row *Row) bool {
+	for _, r := range rows {
+		if r == row {
+			return true
+		}
+	}
+	return false
+}
+
+// keep refuses changing a referenced key of the old item, the caller holds the row lock.
+func (c *Collection) keep(tx *Tx, old, item Item) error {
+	for _, r := range c.referrer {
+		index := c.Indexes[r.Index]
+		if index.Key(old) != index.Key(item) && len(r.referencing(tx, c, old)) > 0 {
+			return &ConstraintError{Constraint: r.Name, Reason: "referenced key cannot change", Err: ErrForeignKey}
+		}
+	}
+	return nil
+}
+
+// change is an item changed by a delete action, kept to restore it when a later action fails.
+type change struct {
+	collection *Collection
+	item       Item
+	deleted    bool
+}
+
+// changes are the changes of the delete actions of a delete, in order.
+type changes []change
+
+// undo restores the items in reverse order, an item deleted meanwhile by another write stays
+// deleted.
+func (l changes) undo(tx *Tx) {
+	for n := len(l) - 1; n >= 0; n-- {
+		c, item := l[n].collection, l[n].item
+		if l[n].deleted {
+			_, _ = c.put(tx, item, 0, true)
+		} else if rows := c.Indexes[0].Get(c.Indexes[0].Key(item)); len(rows) > 0 {
+			_, _ = c.update(tx, rows[0], item, 0)
+		}
+	}
+}
+
+// release applies the delete actions of the foreign keys referencing the item and records them
+// in log. The caller holds the row lock and the read locks of the linked collections.
+func (c *Collection) release(tx *Tx, item Item, log *changes) error {
+	for _, r := range c.referrer {
+		if r.OnDelete == Restrict && len(r.referencing(tx, c, item)) > 0 {
+			return &ConstraintError{Constraint: r.Name, Reason: "item is referenced", Err: ErrForeignKey}
+		}
+	}
+	key := c.Indexes[0].Key(item)
+	for _, r := range c.referrer {
+		for _, child := range r.referencing(tx, c, item) {
+			if r.child == c && c.Indexes[0].Key(child) == key {
+				continue
+			}
+			var err error
+			switch r.OnDelete {
+			case Cascade:
+				_, err = r.child.remove(tx, child, 0, log)
+			case SetNull:
+				err = r.child.nullify(tx, child, r.ForeignKey)
+			}
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			*log = append(*log, change{collection: r.child, item: child, deleted: r.OnDelete == Cascade})
+		}
+	}
+	return nil
+}
+
+// nullify stores the child with the fields of the foreign key set to nil.
+func (c *Collection) nullify(tx *Tx, child Item, fk ForeignKey) error {
+	item := child
+	for _, f := range fk.Field {
+		setter, ok := item.(Setter)
+		if ok {
+			item, ok = setter.Set(f, nil)
+		}
+		if !ok {
+			return &ConstraintError{Constraint: fk.Name, Reason: fmt.Sprintf("field %q cannot be set to nil", f), Err: ErrForeignKey}
+		}
+	}
+	if err := c.validate(item); err != nil {
+		return err
+	}
+	unlock, err := c.check(tx, item)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	rows := c.Indexes[0].Get(c.Indexes[0].Key(item))
+	if len(rows) == 0 {
+		return ErrNotFound
+	}
+	_, err = c.update(tx, rows[0], item, 0)
+	return err
+}
+
+// referencing returns the items of the child collection referring to the item of parent, the
+// caller holds the read locks of both.
+func (r referrer) referencing(tx *Tx, parent *Collection, item Item) []Item {
+	var values []interface{}
+	for _, f := range parent.Indexes[r.Index].Field {
+		values = append(values, item.Field(f))
+	}
+	for _, index := range r.child.Indexes {
+		if equalFields(index.Field, r.Field) && index.Null != NullDistinct {
+			var rows []interface{}
+			for _, row := range index.Get(index.Index(values...)) {
+				rows = append(rows, row)
+			}
+			return r.child.view(tx, rows)
+		}
+	}
+	key := Format(values...)
+	var rows []interface{}
+	r.child.Indexes[0].Range(func(_, value interface{}) bool {
+		if child, _, ok := value.(*Row).get(tx); ok {
+			if v, ok := r.values(child); ok && Format(v...) == key {
+				rows = append(rows, value)
+			}
+		}
+		return true
+	})
+	return r.child.view(tx, rows)
+}
+
+func equalFields(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for n := range a {
+		if a[n] != b[n] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,186 @@
+package memdb
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newForeign(t *testing.T, action Action) (customers, orders *Collection) {
+	customers = newIndexed(
+		Index{
+			Field:   []string{"email"},
+			Mapper:  &UniqueIndex{},
+			Indexer: Format,
+		},
+	)
+	orders = newIndexed()
+	require.NoError(t, orders.AddForeignKey(ForeignKey{Name: "order_customer", Field: []string{"customer"}, References: customers, Index: 1, OnDelete: action}))
+	require.ErrorIs(t, orders.AddForeignKey(ForeignKey{Name: "order_customer", Field: []string{"customer"}, References: customers, Index: 1}), ErrSchema)
+	require.ErrorIs(t, orders.AddForeignKey(ForeignKey{Name: "order_id", Field: []string{"customer"}, References: customers, Index: 2}), ErrSchema)
+	require.ErrorIs(t, orders.AddForeignKey(ForeignKey{Name: "order_id", Field: []string{"customer", "id"}, References: customers, Index: 0}), ErrSchema)
+	for _, item := range []Item{
+		Adapt(map[string]interface{}{"id": 1, "email": "a@x"}),
+		Adapt(map[string]interface{}{"id": 2, "email": "b@x"}),
+	} {
+		_, err := customers.Store(&Tx{}, item, 0)
+		require.NoError(t, err)
+	}
+	for _, item := range []Item{
+		Adapt(map[string]interface{}{"id": 10, "customer": "a@x"}),
+		Adapt(map[string]interface{}{"id": 11, "customer": "a@x"}),
+		Adapt(map[string]interface{}{"id": 12, "customer": "b@x"}),
+		Adapt(map[string]interface{}{"id": 13, "customer": nil}),
+	} {
+		_, err := orders.Store(&Tx{}, item, 0)
+		require.NoError(t, err)
+	}
+	return
+}
+
+func TestCollection_AddForeignKey(t *testing.T) {
+	customers, orders := newForeign(t, Restrict)
+	_, err := orders.Store(&Tx{}, Adapt(map[string]interface{}{"id": 14, "customer": "c@x"}), 0)
+	var constraint *ConstraintError
+	require.True(t, errors.As(err, &constraint))
+	require.Equal(t, "order_customer", constraint.Constraint)
+	require.ErrorIs(t, err, ErrForeignKey)
+	_, ok := orders.Put(&Tx{}, Adapt(map[string]interface{}{"id": 14, "customer": "c@x"}), 0)
+	require.False(t, ok)
+
+	_, err = customers.Remove(&Tx{}, Adapt(map[string]interface{}{"id": 1, "email": "a@x"}), 0)
+	require.ErrorIs(t, err, ErrForeignKey)
+	_, err = customers.Store(&Tx{}, Adapt(map[string]interface{}{"id": 1, "email": "z@x"}), 0)
+	require.ErrorIs(t, err, ErrForeignKey)
+	_, err = orders.Remove(&Tx{}, Adapt(map[string]interface{}{"id": 12}), 0)
+	require.NoError(t, err)
+	_, err = customers.Store(&Tx{}, Adapt(map[string]interface{}{"id": 2, "email": "y@x"}), 0)
+	require.NoError(t, err)
+	_, err = customers.Remove(&Tx{}, Adapt(map[string]interface{}{"id": 2, "email": "y@x"}), 0)
+	require.NoError(t, err)
+	_, err = customers.Remove(&Tx{}, Adapt(map[string]interface{}{"id": 2}), 0)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	customers, orders = newForeign(t, Cascade)
+	_, err = customers.Remove(&Tx{}, Adapt(map[string]interface{}{"id": 1, "email": "a@x"}), 0)
+	require.NoError(t, err)
+	require.Len(t, orders.all(&Tx{}), 2)
+
+	customers, orders = newForeign(t, SetNull)
+	_, err = customers.Remove(&Tx{}, Adapt(map[string]interface{}{"id": 1, "email": "a@x"}), 0)
+	require.NoError(t, err)
+	require.Len(t, orders.all(&Tx{}), 4)
+	for _, id := range []int{10, 11, 13} {
+		require.Nil(t, orders.Get(&Tx{}, 0, []interface{}{id})[0].Field("customer"))
+	}
+
+	employees := newIndexed(
+		Index{
+			Field:   []string{"manager"},
+			Mapper:  &NonUniqueIndex{},
+			Indexer: Format,
+		},
+	)
+	require.NoError(t, employees.AddForeignKey(ForeignKey{Name: "manager", Field: []string{"manager"}, References: employees, OnDelete: Cascade}))
+	for _, item := range []Item{
+		Adapt(map[string]interface{}{"id": 1, "manager": 1}),
+		Adapt(map[string]interface{}{"id": 2, "manager": 1}),
+		Adapt(map[string]interface{}{"id": 3, "manager": 2}),
+		Adapt(map[string]interface{}{"id": 4, "manager": nil}),
+	} {
+		_, err := employees.Store(&Tx{}, item, 0)
+		require.NoError(t, err)
+	}
+	_, err = employees.Remove(&Tx{}, Adapt(map[string]interface{}{"id": 1, "manager": 1}), 0)
+	require.NoError(t, err)
+	require.Equal(t, []Item{Adapt(map[string]interface{}{"id": 4, "manager": nil})}, employees.all(&Tx{}))
+}
+
+func TestCollection_Remove_rollback(t *testing.T) {
+	customers, orders := newForeign(t, Cascade)
+	invoices := newIndexed()
+	invoices.AddRule(Required("customer"))
+	require.NoError(t, invoices.AddForeignKey(ForeignKey{Name: "invoice_customer", Field: []string{"customer"}, References: customers, Index: 1, OnDelete: SetNull}))
+	_, err := invoices.Store(&Tx{}, Adapt(map[string]interface{}{"id": 20, "customer": "a@x"}), 0)
+	require.NoError(t, err)
+	_, err = customers.Remove(&Tx{}, Adapt(map[string]interface{}{"id": 1}), 0)
+	require.ErrorIs(t, err, ErrValidation)
+	require.Len(t, customers.Get(&Tx{}, 0, []interface{}{1}), 1)
+	require.Len(t, orders.all(&Tx{}), 4)
+	require.Equal(t, "a@x", invoices.Get(&Tx{}, 0, []interface{}{20})[0].Field("customer"))
+	require.Empty(t, orders.Verify(&Tx{}, false))
+}
+
+func TestCollection_Store_concurrent(t *testing.T) {
+	customers, orders := newForeign(t, Cascade)
+	var db Database
+	require.NoError(t, db.Register("customers", customers))
+	require.NoError(t, db.Register("orders", orders))
+	var wg sync.WaitGroup
+	for n := 0; n < 8; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			for id := 0; id < 200; id++ {
+				_, err := orders.Store(&Tx{}, Adapt(map[string]interface{}{"id": 100 + n*1000 + id, "customer": "b@x"}), 0)
+				assert.NoError(t, err)
+			}
+		}(n)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for n := 0; n < 100; n++ {
+			_, err := db.Snapshot(&Tx{})
+			assert.NoError(t, err)
+		}
+	}()
+	wg.Wait()
+	<-done
+	_, err := customers.Remove(&Tx{}, Adapt(map[string]interface{}{"id": 2}), 0)
+	require.NoError(t, err)
+	require.Len(t, orders.all(&Tx{}), 3)
+}
+
+func TestCollection_Store_self(t *testing.T) {
+	employees := newIndexed(
+		Index{
+			Field:   []string{"code"},
+			Mapper:  &UniqueIndex{},
+			Indexer: Format,
+		},
+	)
+	require.NoError(t, employees.AddForeignKey(ForeignKey{Name: "manager", Field: []string{"manager"}, References: employees, Index: 1}))
+	_, err := employees.Store(&Tx{}, Adapt(map[string]interface{}{"id": 2, "code": 5}), 0)
+	require.NoError(t, err)
+	_, err = employees.Store(&Tx{}, Adapt(map[string]interface{}{"id": 2, "code": 6, "manager": 5}), 0)
+	require.ErrorIs(t, err, ErrForeignKey)
+	_, err = employees.Store(&Tx{}, Adapt(map[string]interface{}{"id": 2, "code": 5, "manager": 5}), 0)
+	require.NoError(t, err)
+	_, err = employees.Store(&Tx{}, Adapt(map[string]interface{}{"id": 3, "code": 6, "manager": 5}), 0)
+	require.NoError(t, err)
+	require.Empty(t, employees.Verify(&Tx{}, false))
+
+	employees.AddMigration(Migration{From: 0, Migrate: func(item Item) (Item, error) {
+		if item.Field("id") == 2 {
+			item, _ = item.(Adapter).Set("code", 8)
+		}
+		return item, nil
+	}})
+	require.NoError(t, employees.SetVersion(1))
+	require.ErrorIs(t, employees.Migrate(&Tx{}), ErrForeignKey)
+	require.Equal(t, int64(1), employees.MigrationStatus().Failed)
+}
+
+func TestCollection_DropIndex_referenced(t *testing.T) {
+	customers, orders := newForeign(t, Restrict)
+	i, err := customers.AddIndex(&Tx{}, Index{Field: []string{"name"}, Mapper: &NonUniqueIndex{}, Indexer: Format})
+	require.NoError(t, err)
+	require.ErrorIs(t, customers.DropIndex(1), ErrSchema)
+	require.NoError(t, customers.DropIndex(i))
+	_, err = orders.Store(&Tx{}, Adapt(map[string]interface{}{"id": 14, "customer": "b@x"}), 0)
+	require.NoError(t, err)
+}
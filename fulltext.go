@@ -0,0 +1,308 @@
+package memdb
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+type Ranking uint8
+
+const (
+	BM25 Ranking = iota
+	TFIDF
+)
+
+// FullTextIndex is an inverted index over the words of the indexed fields. Every distinct key
+// is a document, the rows stored under the key share its terms.
+type FullTextIndex struct {
+	Tokenizer     func(string) []string
+	Stemmer       func(string) string
+	StopWords     []string
+	CaseSensitive bool
+	Ranking       Ranking
+	mx            sync.RWMutex
+	counter
+	stop   map[string]bool
+	docs   map[string]*document
+	terms  map[string]map[string][]int
+	length int
+}
+
+type document struct {
+	rows  map[interface{}]struct{}
+	terms []string
+}
+
+type hit struct {
+	value interface{}
+	score float64
+}
+
+func (i *FullTextIndex) init() {
+	if i.docs != nil {
+		return
+	}
+	i.stop = make(map[string]bool, len(i.StopWords))
+	i.docs = make(map[string]*document)
+	i.terms = make(map[string]map[string][]int)
+	for _, word := range i.StopWords {
+		i.stop[i.normalize(word)] = true
+	}
+}
+
+func (i *FullTextIndex) normalize(word string) string {
+	if !i.CaseSensitive {
+		word = strings.ToLower(word)
+	}
+	return word
+}
+
+func (i *FullTextIndex) stopped(token string) bool {
+	if i.stop != nil {
+		return i.stop[token]
+	}
+	for _, word := range i.StopWords {
+		if i.normalize(word) == token {
+			return true
+		}
+	}
+	return false
+}
+
+// Analyze splits s into terms the way the index stores them.
+func (i *FullTextIndex) Analyze(s string) []string {
+	tokenizer := i.Tokenizer
+	if tokenizer == nil {
+		tokenizer = Tokenize
+	}
+	stemmer := i.Stemmer
+	if stemmer == nil {
+		stemmer = Stem
+	}
+	var terms []string
+	for _, token := range tokenizer(s) {
+		token = i.normalize(token)
+		if i.stopped(token) {
+			continue
+		}
+		terms = append(terms, stemmer(token))
+	}
+	return terms
+}
+
+func (i *FullTextIndex) Load(key interface{}) (values []interface{}, ok bool) {
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	for _, h := range i.search([][][]string{i.clauses(key.(string))}) {
+		values = append(values, h.value)
+	}
+	return values, len(values) > 0
+}
+
+func (i *FullTextIndex) LoadOrStore(key, value interface{}) (interface{}, bool) {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	i.init()
+	doc, ok := i.docs[key.(string)]
+	if !ok {
+		doc = &document{rows: map[interface{}]struct{}{}, terms: i.Analyze(key.(string))}
+		for n, term := range doc.terms {
+			postings, ok := i.terms[term]
+			if !ok {
+				postings = make(map[string][]int)
+				i.terms[term] = postings
+			}
+			postings[key.(string)] = append(postings[key.(string)], n)
+		}
+		i.docs[key.(string)] = doc
+		i.length += len(doc.terms)
+	}
+	_, ok = doc.rows[value]
+	if !ok {
+		doc.rows[value] = struct{}{}
+		i.add(key, int64(len(doc.rows)))
+	}
+	return value, ok
+}
+
+func (i *FullTextIndex) LoadAndDelete(key, value interface{}) (interface{}, bool) {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	doc, ok := i.docs[key.(string)]
+	if !ok {
+		return nil, false
+	}
+	_, ok = doc.rows[value]
+	if !ok {
+		return nil, false
+	}
+	delete(doc.rows, value)
+	i.remove(key, int64(len(doc.rows)))
+	if len(doc.rows) == 0 {
+		for _, term := range doc.terms {
+			delete(i.terms[term], key.(string))
+			if len(i.terms[term]) == 0 {
+				delete(i.terms, term)
+			}
+		}
+		delete(i.docs, key.(string))
+		i.length -= len(doc.terms)
+	}
+	return value, true
+}
+
+func (i *FullTextIndex) Range(f func(key, value interface{}) bool) {
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	for key, doc := range i.docs {
+		for value := range doc.rows {
+			if !f(key, value) {
+				return
+			}
+		}
+	}
+}
+
+// Search returns the values of documents matching the query, best ranked first. Words of the
+// query must all occur in a document, OR separates alternatives and double quotes enclose a phrase.
+func (i *FullTextIndex) Search(query string) []interface{} {
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	var groups [][][]string
+	var clauses [][]string
+	for _, part := range splitQuery(query) {
+		if part == "OR" {
+			groups, clauses = append(groups, clauses), nil
+			continue
+		}
+		clauses = append(clauses, i.Analyze(part))
+	}
+	var values []interface{}
+	for _, h := range i.search(append(groups, clauses)) {
+		values = append(values, h.value)
+	}
+	return values
+}
+
+// Search returns items of the full-text index i matching the query, best ranked first.
+func (c *Collection) Search(tx *Tx, i int, query string) []Item {
+	return c.items(tx, c.index(i).Mapper.(*FullTextIndex).Search(query))
+}
+
+func (i *FullTextIndex) clauses(s string) (clauses [][]string) {
+	for _, term := range i.Analyze(s) {
+		clauses = append(clauses, []string{term})
+	}
+	return
+}
+
+func (i *FullTextIndex) search(groups [][][]string) []hit {
+	scores := map[string]float64{}
+	for _, clauses := range groups {
+		var keys map[string]bool
+		for _, clause := range clauses {
+			if len(clause) == 0 {
+				continue
+			}
+			matched := map[string]bool{}
+			for key := range i.terms[clause[0]] {
+				if (keys == nil || keys[key]) && i.phrase(key, clause) {
+					matched[key] = true
+				}
+			}
+			keys = matched
+		}
+		for key := range keys {
+			var score float64
+			for _, clause := range clauses {
+				for _, term := range clause {
+					score += i.score(key, term)
+				}
+			}
+			if last, ok := scores[key]; !ok || score > last {
+				scores[key] = score
+			}
+		}
+	}
+	var hits []hit
+	for key, score := range scores {
+		for value := range i.docs[key].rows {
+			hits = append(hits, hit{value: value, score: score})
+		}
+	}
+	sort.SliceStable(hits, func(a, b int) bool {
+		return hits[a].score > hits[b].score
+	})
+	return hits
+}
+
+func (i *FullTextIndex) phrase(key string, terms []string) bool {
+	for _, n := range i.terms[terms[0]][key] {
+		ok := true
+		for k, term := range terms[1:] {
+			if !contains(i.terms[term][key], n+k+1) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *FullTextIndex) score(key, term string) float64 {
+	tf := float64(len(i.terms[term][key]))
+	df := float64(len(i.terms[term]))
+	n := float64(len(i.docs))
+	switch i.Ranking {
+	case TFIDF:
+		return tf * math.Log(n/df)
+	default:
+		const k1, b = 1.2, 0.75
+		avg := float64(i.length) / n
+		dl := float64(len(i.docs[key].terms))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		return idf * tf * (k1 + 1) / (tf + k1*(1-b+b*dl/avg))
+	}
+}
+
+func contains(positions []int, n int) bool {
+	k := sort.SearchInts(positions, n)
+	return k < len(positions) && positions[k] == n
+}
+
+func splitQuery(query string) (parts []string) {
+	for n, part := range strings.Split(query, `"`) {
+		if n%2 == 1 {
+			parts = append(parts, part)
+			continue
+		}
+		parts = append(parts, strings.Fields(part)...)
+	}
+	return
+}
+
+// Tokenize splits s into words of letters and digits.
+func Tokenize(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Stem strips common English inflectional suffixes from a word.
+func Stem(word string) string {
+	for _, s := range [...]struct{ suffix, replace string }{
+		{"sses", "ss"}, {"ches", "ch"}, {"shes", "sh"}, {"xes", "x"}, {"ies", "y"},
+		{"ing", ""}, {"ed", ""}, {"ly", ""}, {"ss", "ss"}, {"s", ""},
+	} {
+		if strings.HasSuffix(word, s.suffix) && len(word)-len(s.suffix)+len(s.replace) >= 3 {
+			return word[:len(word)-len(s.suffix)] + s.replace
+		}
+	}
+	return word
+}
@@ -0,0 +1,51 @@
+package memdb
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollection_Search(t *testing.T) {
+	collection := newIndexed(
+		Index{
+			Field:   []string{"type"},
+			Mapper:  &FullTextIndex{StopWords: []string{"the", "a"}},
+			Indexer: Format,
+		},
+	)
+	item := []Item{
+		X1{ID: uuid.New(), Type: "The quick brown fox jumps over the lazy dog"},
+		X1{ID: uuid.New(), Type: "A lazy brown dog sleeping"},
+		X1{ID: uuid.New(), Type: "Quick foxes"},
+	}
+	for _, x := range item {
+		_, ok := collection.Put(&Tx{}, x, 0)
+		require.True(t, ok)
+	}
+	tests := []struct {
+		name  string
+		query string
+		want  []Item
+	}{
+		{name: "and", query: "lazy dog", want: []Item{item[1], item[0]}},
+		{name: "stem", query: "fox", want: []Item{item[2], item[0]}},
+		{name: "or", query: "sleep OR jumped", want: []Item{item[1], item[0]}},
+		{name: "phrase", query: `"brown dog"`, want: []Item{item[1]}},
+		{name: "stop words", query: `"jumps over the lazy"`, want: []Item{item[0]}},
+		{name: "none", query: "cat", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, collection.Search(&Tx{}, 1, tt.query))
+		})
+	}
+	require.ElementsMatch(t, []Item{item[1], item[0]}, collection.Get(&Tx{}, 1, []interface{}{"DOG"}))
+	_, ok := collection.Put(&Tx{}, X1{ID: item[1].(X1).ID, Type: "A lazy cat"}, 0)
+	require.True(t, ok)
+	require.Equal(t, []Item{item[0]}, collection.Search(&Tx{}, 1, "dog"))
+	_, ok = collection.Delete(&Tx{}, item[0], 0)
+	require.True(t, ok)
+	require.Empty(t, collection.Search(&Tx{}, 1, "dog"))
+}
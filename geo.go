@@ -0,0 +1,323 @@
+package memdb
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+const earthRadius = 6371008.8
+
+// BBox is a latitude/longitude bounding box in degrees, a box with MinLon greater than MaxLon
+// crosses the antimeridian.
+type BBox struct {
+	MinLat, MinLon float64
+	MaxLat, MaxLon float64
+}
+
+// GeoIndex buckets points by geohash. The indexed fields are latitude and longitude in degrees,
+// the key is made by the LatLon indexer.
+type GeoIndex struct {
+	Precision int
+	mx        sync.RWMutex
+	counter
+	points map[string]*point
+	cells  map[string]map[string]struct{}
+}
+
+type point struct {
+	lat, lon float64
+	cell     string
+	rows     map[interface{}]struct{}
+}
+
+type distance struct {
+	value interface{}
+	meter float64
+}
+
+// LatLon is an Indexer which encodes a latitude and a longitude of any numeric type as a binary
+// key, values which are not a point make an empty key.
+func LatLon(values ...interface{}) string {
+	if len(values) != 2 {
+		return ""
+	}
+	var buf [16]byte
+	for n, value := range values {
+		v := reflect.ValueOf(value)
+		var f float64
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			f = v.Float()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f = float64(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			f = float64(v.Uint())
+		default:
+			return ""
+		}
+		binary.BigEndian.PutUint64(buf[8*n:], math.Float64bits(f))
+	}
+	return string(buf[:])
+}
+
+func pointOf(key string) (lat, lon float64, ok bool) {
+	if len(key) != 16 {
+		return
+	}
+	lat = math.Float64frombits(binary.BigEndian.Uint64([]byte(key[:8])))
+	lon = math.Float64frombits(binary.BigEndian.Uint64([]byte(key[8:])))
+	return lat, lon, lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180
+}
+
+// Near returns items of the geospatial index i within radius meters of the point, nearest first.
+func (c *Collection) Near(tx *Tx, i int, lat, lon, radius float64) []Item {
+	return c.items(tx, c.index(i).Mapper.(*GeoIndex).Near(lat, lon, radius))
+}
+
+// Within returns items of the geospatial index i inside the box, nearest to its center first.
+func (c *Collection) Within(tx *Tx, i int, box BBox) []Item {
+	return c.items(tx, c.index(i).Mapper.(*GeoIndex).Within(box))
+}
+
+func (i *GeoIndex) precision() int {
+	if i.Precision < 1 {
+		return 5
+	}
+	return i.Precision
+}
+
+func (i *GeoIndex) Load(key interface{}) (values []interface{}, ok bool) {
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	p, ok := i.points[key.(string)]
+	if ok {
+		for value := range p.rows {
+			values = append(values, value)
+		}
+	}
+	return
+}
+
+func (i *GeoIndex) LoadOrStore(key, value interface{}) (interface{}, bool) {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	if i.points == nil {
+		i.points = make(map[string]*point)
+		i.cells = make(map[string]map[string]struct{})
+	}
+	p, ok := i.points[key.(string)]
+	if !ok {
+		p = &point{rows: map[interface{}]struct{}{}}
+		if lat, lon, ok := pointOf(key.(string)); ok {
+			p.lat, p.lon, p.cell = lat, lon, Geohash(lat, lon, i.precision())
+			cell, ok := i.cells[p.cell]
+			if !ok {
+				cell = make(map[string]struct{})
+				i.cells[p.cell] = cell
+			}
+			cell[key.(string)] = struct{}{}
+		}
+		i.points[key.(string)] = p
+	}
+	_, ok = p.rows[value]
+	if !ok {
+		p.rows[value] = struct{}{}
+		i.add(key, int64(len(p.rows)))
+	}
+	return value, ok
+}
+
+func (i *GeoIndex) LoadAndDelete(key, value interface{}) (interface{}, bool) {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	p, ok := i.points[key.(string)]
+	if !ok {
+		return nil, false
+	}
+	_, ok = p.rows[value]
+	if !ok {
+		return nil, false
+	}
+	delete(p.rows, value)
+	i.remove(key, int64(len(p.rows)))
+	if len(p.rows) == 0 {
+		if p.cell != "" {
+			delete(i.cells[p.cell], key.(string))
+			if len(i.cells[p.cell]) == 0 {
+				delete(i.cells, p.cell)
+			}
+		}
+		delete(i.points, key.(string))
+	}
+	return value, true
+}
+
+func (i *GeoIndex) Range(f func(key, value interface{}) bool) {
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	for key, p := range i.points {
+		for value := range p.rows {
+			if !f(key, value) {
+				return
+			}
+		}
+	}
+}
+
+// Near returns the values within radius meters of the point, nearest first.
+func (i *GeoIndex) Near(lat, lon, radius float64) []interface{} {
+	d := radius / earthRadius * 180 / math.Pi
+	box := BBox{MinLat: lat - d, MaxLat: lat + d, MinLon: lon - 180, MaxLon: lon + 180}
+	if cos := math.Cos(lat * math.Pi / 180); d/cos < 180 {
+		box.MinLon, box.MaxLon = wrap(lon-d/cos), wrap(lon+d/cos)
+	}
+	return i.scan(box, lat, lon, func(p *point) bool {
+		return Haversine(lat, lon, p.lat, p.lon) <= radius
+	})
+}
+
+// Within returns the values inside the box, nearest to its center first.
+func (i *GeoIndex) Within(box BBox) []interface{} {
+	lon := (box.MinLon + box.MaxLon) / 2
+	if box.MinLon > box.MaxLon {
+		lon = wrap(lon + 180)
+	}
+	return i.scan(box, (box.MinLat+box.MaxLat)/2, lon, func(p *point) bool {
+		if p.lat < box.MinLat || p.lat > box.MaxLat {
+			return false
+		}
+		if box.MinLon > box.MaxLon {
+			return p.lon >= box.MinLon || p.lon <= box.MaxLon
+		}
+		return p.lon >= box.MinLon && p.lon <= box.MaxLon
+	})
+}
+
+// wrap puts a longitude into the range from -180 to 180.
+func wrap(lon float64) float64 {
+	switch {
+	case lon < -180:
+		return lon + 360
+	case lon > 180:
+		return lon - 360
+	}
+	return lon
+}
+
+func (i *GeoIndex) scan(box BBox, lat, lon float64, match func(*point) bool) []interface{} {
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	var found []distance
+	visit := func(key string) {
+		p := i.points[key]
+		if p.cell == "" || !match(p) {
+			return
+		}
+		meter := Haversine(lat, lon, p.lat, p.lon)
+		for value := range p.rows {
+			found = append(found, distance{value: value, meter: meter})
+		}
+	}
+	if cells, ok := i.cover(box); ok {
+		for _, cell := range cells {
+			for key := range i.cells[cell] {
+				visit(key)
+			}
+		}
+	} else {
+		for key := range i.points {
+			visit(key)
+		}
+	}
+	sort.SliceStable(found, func(a, b int) bool {
+		return found[a].meter < found[b].meter
+	})
+	values := make([]interface{}, 0, len(found))
+	for _, d := range found {
+		values = append(values, d.value)
+	}
+	return values
+}
+
+// cover lists geohash cells overlapping the box, a box crossing the antimeridian is split in
+// two. It fails for boxes spanning too many cells or wrapping around a pole.
+func (i *GeoIndex) cover(box BBox) ([]string, bool) {
+	const limit = 1024
+	if box.MinLon > box.MaxLon {
+		east, west := box, box
+		east.MaxLon, west.MinLon = 180, -180
+		cells, ok := i.cover(east)
+		if !ok {
+			return nil, false
+		}
+		more, ok := i.cover(west)
+		if !ok || len(cells)+len(more) > limit {
+			return nil, false
+		}
+		return append(cells, more...), true
+	}
+	if box.MinLat < -90 || box.MaxLat > 90 || box.MinLon < -180 || box.MaxLon > 180 {
+		return nil, false
+	}
+	bits := 5 * i.precision()
+	height := 180 / math.Exp2(float64(bits/2))
+	width := 360 / math.Exp2(float64(bits-bits/2))
+	rows := math.Floor(box.MaxLat/height) - math.Floor(box.MinLat/height) + 1
+	cols := math.Floor(box.MaxLon/width) - math.Floor(box.MinLon/width) + 1
+	if rows*cols > limit {
+		return nil, false
+	}
+	// A box on the lat=90 or lon=180 edge has an extra row or column which clamps into the cells
+	// of the one before it.
+	var cells []string
+	seen := make(map[string]bool)
+	for y := 0.0; y < rows; y++ {
+		for x := 0.0; x < cols; x++ {
+			lat := math.Min((math.Floor(box.MinLat/height)+y+0.5)*height, box.MaxLat)
+			lon := math.Min((math.Floor(box.MinLon/width)+x+0.5)*width, box.MaxLon)
+			if cell := Geohash(math.Max(lat, box.MinLat), math.Max(lon, box.MinLon), i.precision()); !seen[cell] {
+				seen[cell] = true
+				cells = append(cells, cell)
+			}
+		}
+	}
+	return cells, true
+}
+
+// Geohash encodes the point as a geohash of the given length.
+func Geohash(lat, lon float64, precision int) string {
+	const base32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+	minLat, maxLat, minLon, maxLon := -90.0, 90.0, -180.0, 180.0
+	hash := make([]byte, precision)
+	for n, bit := 0, 0; n < precision*5; n++ {
+		bit <<= 1
+		if n%2 == 0 {
+			if mid := (minLon + maxLon) / 2; lon >= mid {
+				bit, minLon = bit|1, mid
+			} else {
+				maxLon = mid
+			}
+		} else {
+			if mid := (minLat + maxLat) / 2; lat >= mid {
+				bit, minLat = bit|1, mid
+			} else {
+				maxLat = mid
+			}
+		}
+		if n%5 == 4 {
+			hash[n/5], bit = base32[bit], 0
+		}
+	}
+	return string(hash)
+}
+
+// Haversine returns the great-circle distance in meters between two points.
+func Haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	const rad = math.Pi / 180
+	dLat, dLon := (lat2-lat1)*rad, (lon2-lon1)*rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
@@ -0,0 +1,89 @@
+package memdb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type P1 struct {
+	ID  int
+	Lat float64
+	Lon float64
+}
+
+func (p P1) Copy(Item) (Item, bool) {
+	return p, true
+}
+
+func (p P1) Field(name string) interface{} {
+	switch name {
+	case "id":
+		return p.ID
+	case "lat":
+		return p.Lat
+	case "lon":
+		return p.Lon
+	default:
+		panic(name)
+	}
+}
+
+func TestCollection_Near(t *testing.T) {
+	collection := newIndexed(
+		Index{
+			Field:   []string{"lat", "lon"},
+			Mapper:  &GeoIndex{},
+			Indexer: LatLon,
+		},
+	)
+	item := []Item{
+		P1{ID: 1, Lat: 55.7539, Lon: 37.6208}, // Red Square
+		P1{ID: 2, Lat: 55.7520, Lon: 37.6175}, // Kremlin
+		P1{ID: 3, Lat: 55.7298, Lon: 37.6010}, // Gorky Park
+		P1{ID: 4, Lat: 59.9398, Lon: 30.3146}, // Hermitage
+	}
+	for _, p := range item {
+		_, ok := collection.Put(&Tx{}, p, 0)
+		require.True(t, ok)
+	}
+	require.Equal(t, []Item{item[1], item[0]}, collection.Near(&Tx{}, 1, 55.7520, 37.6175, 1000))
+	require.Equal(t, []Item{item[1], item[0], item[2]}, collection.Near(&Tx{}, 1, 55.7520, 37.6175, 5000))
+	require.Equal(t, []Item{item[3], item[0], item[1], item[2]}, collection.Near(&Tx{}, 1, 59.9398, 30.3146, 1e7))
+	require.Equal(t, []Item{item[2], item[1]}, collection.Within(&Tx{}, 1, BBox{MinLat: 55.72, MinLon: 37.60, MaxLat: 55.753, MaxLon: 37.62}))
+	_, ok := collection.Put(&Tx{}, P1{ID: 2, Lat: 59.9343, Lon: 30.3351}, 0)
+	require.True(t, ok)
+	require.Equal(t, []Item{item[0]}, collection.Near(&Tx{}, 1, 55.7520, 37.6175, 1000))
+	_, ok = collection.Delete(&Tx{}, item[0], 0)
+	require.True(t, ok)
+	require.Empty(t, collection.Near(&Tx{}, 1, 55.7520, 37.6175, 1000))
+}
+
+func TestCollection_Within_antimeridian(t *testing.T) {
+	collection := newIndexed(
+		Index{
+			Field:   []string{"lat", "lon"},
+			Mapper:  &GeoIndex{Precision: 3},
+			Indexer: LatLon,
+		},
+	)
+	item := []Item{
+		P1{ID: 1, Lat: -17.7134, Lon: 178.0650},  // Fiji
+		P1{ID: 2, Lat: -13.7590, Lon: -172.1046}, // Samoa
+		P1{ID: 3, Lat: -18.1416, Lon: 170.0},
+		P1{ID: 4, Lat: 0, Lon: 179.99},
+	}
+	for _, p := range item {
+		_, ok := collection.Put(&Tx{}, p, 0)
+		require.True(t, ok)
+	}
+	require.ElementsMatch(t, []Item{item[0], item[1]}, collection.Within(&Tx{}, 1, BBox{MinLat: -20, MinLon: 175, MaxLat: -10, MaxLon: -170}))
+	require.Equal(t, []Item{item[1], item[0]}, collection.Near(&Tx{}, 1, -14, -175, 1e6))
+	require.Equal(t, []Item{item[3]}, collection.Near(&Tx{}, 1, 0, 179.995, 5000))
+	require.Equal(t, []Item{item[3]}, collection.Within(&Tx{}, 1, BBox{MinLat: -1, MinLon: 179, MaxLat: 1, MaxLon: 180}))
+}
+
+func TestGeohash(t *testing.T) {
+	require.Equal(t, "ezs42", Geohash(42.6, -5.6, 5))
+	require.Equal(t, "u4pruydqqvj", Geohash(57.64911, 10.40744, 11))
+}
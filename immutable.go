@@ -0,0 +1,50 @@
+package memdb
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var ErrImmutable = errors.New("memdb: immutable field changed")
+
+// AddImmutable declares fields which keep the value an item was created with, writes of an
+// item changing them are refused with a *ConstraintError wrapping ErrImmutable.
+func (c *Collection) AddImmutable(fields ...string) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.immutable = append(c.immutable[:len(c.immutable):len(c.immutable)], fields...)
+}
+
+// Immutable returns the immutable fields of the collection.
+func (c *Collection) Immutable() []string {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	return append([]string(nil), c.immutable...)
+}
+
+// unchanged checks that the item changes no immutable field of the old item it replaces, the
+// caller holds the row lock.
+func (c *Collection) unchanged(old, item Item) error {
+	for _, f := range c.immutable {
+		if was, value := old.Field(f), item.Field(f); !same(was, value) {
+			return &ConstraintError{
+				Constraint: f,
+				Reason:     fmt.Sprintf("changed from %v to %v", was, value),
+				Err:        ErrImmutable,
+			}
+		}
+	}
+	return nil
+}
+
+// same compares field values, times are the same at the same instant whatever their location
+// or monotonic clock reading.
+func same(a, b interface{}) bool {
+	if x, ok := a.(time.Time); ok {
+		y, ok := b.(time.Time)
+		return ok && x.Equal(y)
+	}
+	return reflect.DeepEqual(a, b)
+}
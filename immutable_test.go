@@ -0,0 +1,105 @@
+package memdb
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollection_AddImmutable(t *testing.T) {
+	type account struct {
+		ID    int    `json:"id" memdb:"pk"`
+		Owner int    `json:"owner" memdb:"index;immutable"`
+		Login string `json:"login" memdb:"unique;immutable"`
+		Name  string `json:"name"`
+	}
+	schema, err := SchemaOf(account{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"owner", "login"}, schema.Immutable)
+	collection, err := NewCollection(schema)
+	require.NoError(t, err)
+	require.Equal(t, []string{"owner", "login"}, collection.Immutable())
+
+	_, err = collection.Store(&Tx{}, Adapt(account{ID: 1, Owner: 7, Login: "one"}), 0)
+	require.NoError(t, err)
+	_, err = collection.Store(&Tx{}, Adapt(account{ID: 1, Owner: 7, Login: "one", Name: "One"}), 0)
+	require.NoError(t, err)
+	_, err = collection.Store(&Tx{}, Adapt(account{ID: 1, Owner: 7, Login: "uno", Name: "One"}), 0)
+	require.ErrorIs(t, err, ErrImmutable)
+	var constraint *ConstraintError
+	require.True(t, errors.As(err, &constraint))
+	require.Equal(t, "login", constraint.Constraint)
+	_, err = collection.Store(&Tx{}, Adapt(account{ID: 1, Owner: 8, Login: "one"}), 0)
+	require.ErrorIs(t, err, ErrImmutable)
+	require.Len(t, collection.Get(&Tx{}, 2, []interface{}{"one"}), 1)
+	require.Empty(t, collection.Get(&Tx{}, 2, []interface{}{"uno"}))
+
+	_, err = collection.Remove(&Tx{}, Adapt(account{ID: 1}), 0)
+	require.NoError(t, err)
+	_, err = collection.Store(&Tx{}, Adapt(account{ID: 1, Owner: 8, Login: "uno"}), 0)
+	require.NoError(t, err)
+
+	collection.AddImmutable("name")
+	_, err = collection.Store(&Tx{}, Adapt(account{ID: 1, Owner: 8, Login: "uno", Name: "Uno"}), 0)
+	require.ErrorIs(t, err, ErrImmutable)
+
+	_, err = NewCollection(Schema{
+		Type:      reflect.TypeOf(account{}),
+		Indexes:   []IndexSchema{{Field: []string{"id"}, Unique: true}},
+		Immutable: []string{"created"},
+	})
+	require.ErrorIs(t, err, ErrSchema)
+	_, err = SchemaOf(struct {
+		ID int `memdb:"pk;immutable,group=id"`
+	}{})
+	require.ErrorIs(t, err, ErrSchema)
+}
+
+func TestCollection_AddImmutable_concurrent(t *testing.T) {
+	collection := newIndexed()
+	collection.AddImmutable("login")
+	for id := 0; id < 100; id++ {
+		var wg sync.WaitGroup
+		errs := make([]error, 2)
+		for n, login := range []string{"a", "b"} {
+			wg.Add(1)
+			go func(n int, login string) {
+				defer wg.Done()
+				_, errs[n] = collection.Store(&Tx{}, Adapt(map[string]interface{}{"id": id, "login": login}), 0)
+			}(n, login)
+		}
+		wg.Wait()
+		stored := collection.Get(&Tx{}, 0, []interface{}{id})[0].Field("login")
+		for n, login := range []string{"a", "b"} {
+			if errs[n] == nil {
+				require.Equal(t, login, stored)
+			} else {
+				require.ErrorIs(t, errs[n], ErrImmutable)
+			}
+		}
+	}
+}
+
+func TestCollection_AddImmutable_time(t *testing.T) {
+	type event struct {
+		ID      int       `json:"id" memdb:"pk"`
+		Created time.Time `json:"created" memdb:"immutable"`
+	}
+	schema, err := SchemaOf(event{})
+	require.NoError(t, err)
+	collection, err := NewCollection(schema)
+	require.NoError(t, err)
+	created := time.Now()
+	_, err = collection.Store(&Tx{}, Adapt(event{ID: 1, Created: created}), 0)
+	require.NoError(t, err)
+	_, err = collection.Store(&Tx{}, Adapt(event{ID: 1, Created: created.Round(0)}), 0)
+	require.NoError(t, err)
+	_, err = collection.Store(&Tx{}, Adapt(event{ID: 1, Created: created.UTC()}), 0)
+	require.NoError(t, err)
+	_, err = collection.Store(&Tx{}, Adapt(event{ID: 1, Created: created.Add(time.Nanosecond)}), 0)
+	require.ErrorIs(t, err, ErrImmutable)
+}
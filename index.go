@@ -3,14 +3,41 @@ package memdb
 import (
 	"bytes"
 	"fmt"
+	"reflect"
 )
 
 type Indexer func(...interface{}) string
 
+// Null tells how a secondary index treats items whose indexed fields are nil.
+type Null uint8
+
+const (
+	// NullIndexed indexes nil like any other value.
+	NullIndexed Null = iota
+	// NullSkipAll leaves out items whose indexed fields are all nil.
+	NullSkipAll
+	// NullSkipAny leaves out items with any nil indexed field.
+	NullSkipAny
+	// NullDistinct keeps items with nil fields apart, so a unique index admits any number of them.
+	NullDistinct
+)
+
+// Direction is the sort order of an indexed field.
+type Direction uint8
+
+const (
+	Asc Direction = iota
+	Desc
+)
+
 type Index struct {
 	Indexer
 	Mapper
-	Field []string
+	Field     []string
+	Collation Collation
+	Null      Null
+	Order     []Direction
+	Sketch    *Sketch
 }
 
 func (i Index) Get(key string) (rows []*Row) {
@@ -25,6 +52,9 @@ func (i Index) Get(key string) (rows []*Row) {
 
 func (i Index) Put(key string, row *Row) (*Row, bool) {
 	v, ok := i.LoadOrStore(key, row)
+	if !ok && i.Sketch != nil {
+		i.Sketch.add(key)
+	}
 	return v.(*Row), ok
 }
 
@@ -36,13 +66,66 @@ func (i Index) Key(item Item) string {
 	return i.Index(values...)
 }
 
+func (i Index) key(item Item, row *Row) (string, bool) {
+	var values []interface{}
+	var nils int
+	for _, f := range i.Field {
+		value := item.Field(f)
+		if isNil(value) {
+			nils++
+		}
+		values = append(values, value)
+	}
+	switch {
+	case nils == 0 || i.Null == NullIndexed:
+	case i.Null == NullSkipAny, i.Null == NullSkipAll && nils == len(values):
+		return "", false
+	case i.Null == NullDistinct:
+		return fmt.Sprintf("%s::%p", i.Index(values...), row), true
+	}
+	return i.Index(values...), true
+}
+
 func (i Index) Index(values ...interface{}) string {
-	return i.Indexer(values...)
+	if i.Collation != 0 {
+		values = i.Collation.collate(values)
+	}
+	if len(i.Order) == 0 {
+		return i.Indexer(values...)
+	}
+	var b bytes.Buffer
+	for n, value := range values {
+		key := i.Indexer(value)
+		if i.direction(n) == Desc {
+			key = invert(key)
+		}
+		b.WriteString(key)
+	}
+	return b.String()
+}
+
+func (i Index) direction(n int) Direction {
+	if n < len(i.Order) {
+		return i.Order[n]
+	}
+	return Asc
+}
+
+// invert complements every byte of key, which reverses the order of self-delimiting keys.
+func invert(key string) string {
+	b := []byte(key)
+	for n := range b {
+		b[n] = ^b[n]
+	}
+	return string(b)
 }
 
 func (i Index) Pop(key string, row *Row) (*Row, bool) {
 	v, ok := i.LoadAndDelete(key, row)
 	if ok {
+		if i.Sketch != nil {
+			i.Sketch.remove(key)
+		}
 		return v.(*Row), true
 	}
 	return nil, false
@@ -55,3 +138,14 @@ func Format(values ...interface{}) string {
 	}
 	return b.String()
 }
+
+func isNil(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	}
+	return false
+}
@@ -27,3 +27,128 @@ func TestFormat(t *testing.T) {
 		})
 	}
 }
+
+type N1 struct {
+	ID   int
+	A, B *int
+}
+
+func (n N1) Copy(Item) (Item, bool) {
+	return n, true
+}
+
+func (n N1) Field(name string) interface{} {
+	switch name {
+	case "id":
+		return n.ID
+	case "a":
+		return n.A
+	case "b":
+		return n.B
+	default:
+		panic(name)
+	}
+}
+
+func TestIndex_Null(t *testing.T) {
+	one, two := 1, 2
+	tests := []struct {
+		name  string
+		null  Null
+		items []N1
+		ok    []bool
+		count int
+	}{
+		{
+			name:  "indexed",
+			null:  NullIndexed,
+			items: []N1{{ID: 1}, {ID: 2}, {ID: 3, A: &one}},
+			ok:    []bool{true, false, true},
+			count: 2,
+		}, {
+			name:  "skip all",
+			null:  NullSkipAll,
+			items: []N1{{ID: 1}, {ID: 2}, {ID: 3, A: &one}, {ID: 4, A: &one}},
+			ok:    []bool{true, true, true, false},
+			count: 1,
+		}, {
+			name:  "skip any",
+			null:  NullSkipAny,
+			items: []N1{{ID: 1, A: &one}, {ID: 2, A: &one}, {ID: 3, A: &one, B: &two}},
+			ok:    []bool{true, true, true},
+			count: 1,
+		}, {
+			name:  "distinct",
+			null:  NullDistinct,
+			items: []N1{{ID: 1, A: &one}, {ID: 2, A: &one}, {ID: 3, A: &one, B: &two}, {ID: 4, A: &one, B: &two}},
+			ok:    []bool{true, true, true, false},
+			count: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collection := newIndexed(
+				Index{
+					Field:   []string{"a", "b"},
+					Mapper:  &UniqueIndex{},
+					Indexer: Format,
+					Null:    tt.null,
+				},
+			)
+			for n, item := range tt.items {
+				_, ok := collection.Put(&Tx{}, item, 0)
+				if ok != tt.ok[n] {
+					t.Errorf("Put(%v) ok = %v, want %v", item.ID, ok, tt.ok[n])
+				}
+			}
+			var count int
+			collection.Indexes[1].Range(func(_, _ interface{}) bool {
+				count++
+				return true
+			})
+			if count != tt.count {
+				t.Errorf("Range() count = %v, want %v", count, tt.count)
+			}
+			for n, item := range tt.items {
+				if tt.ok[n] {
+					collection.Delete(&Tx{}, item, 0)
+				}
+			}
+			collection.Indexes[1].Range(func(key, _ interface{}) bool {
+				t.Errorf("Range() key = %v after delete", key)
+				return true
+			})
+		})
+	}
+}
+
+func TestOrdered(t *testing.T) {
+	sorted := [][]interface{}{
+		{nil},
+		{false},
+		{true},
+		{-1 << 40},
+		{-1},
+		{0},
+		{uint8(1)},
+		{int64(1) << 40},
+		{uint64(1) << 63},
+		{-1.5},
+		{0.0},
+		{2.25},
+		{""},
+		{"", 1},
+		{"\x00"},
+		{"\x00\x00"},
+		{"a"},
+		{"a", "b"},
+		{"ab"},
+		{"b\x00a"},
+		{"b\x01"},
+	}
+	for n := 1; n < len(sorted); n++ {
+		if a, b := Ordered(sorted[n-1]...), Ordered(sorted[n]...); a >= b {
+			t.Errorf("Ordered(%q) = %q >= Ordered(%q) = %q", sorted[n-1], a, sorted[n], b)
+		}
+	}
+}
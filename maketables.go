@@ -0,0 +1,240 @@
+//go:build ignore
+// +build ignore
+
+// Maketables generates tables.go from the Unicode Character Database. It reads
+// UnicodeData.txt, DerivedNormalizationProps.txt and CaseFolding.txt from -ucd, which is a
+// URL or a local directory.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const version = "14.0.0"
+
+var ucd = flag.String("ucd", "https://www.unicode.org/Public/"+version+"/ucd/", "URL or directory of the UCD files")
+var output = flag.String("output", "tables.go", "output file name")
+
+func main() {
+	flag.Parse()
+	canonical := map[rune][]rune{}
+	compatibility := map[rune][]rune{}
+	classes := map[rune]uint8{}
+	parse("UnicodeData.txt", func(fields []string) {
+		r := code(fields[0])
+		if ccc, err := strconv.Atoi(fields[3]); err != nil {
+			log.Fatal(err)
+		} else if ccc != 0 {
+			classes[r] = uint8(ccc)
+		}
+		if d := fields[5]; strings.HasPrefix(d, "<") {
+			compatibility[r] = codes(d[strings.IndexByte(d, '>')+1:])
+		} else if d != "" {
+			canonical[r] = codes(d)
+		}
+	})
+	excluded := map[rune]bool{}
+	parse("DerivedNormalizationProps.txt", func(fields []string) {
+		if fields[1] != "Full_Composition_Exclusion" {
+			return
+		}
+		first, last := fields[0], fields[0]
+		if n := strings.Index(first, ".."); n >= 0 {
+			first, last = first[:n], first[n+2:]
+		}
+		for r := code(first); r <= code(last); r++ {
+			excluded[r] = true
+		}
+	})
+	foldings := map[rune]string{}
+	parse("CaseFolding.txt", func(fields []string) {
+		if fields[1] == "C" || fields[1] == "F" {
+			foldings[code(fields[0])] = string(codes(fields[2]))
+		}
+	})
+
+	var decompose func(r rune, compatible bool) []rune
+	decompose = func(r rune, compatible bool) []rune {
+		if hangul(r) {
+			return hangulDecompose(r)
+		}
+		d, ok := canonical[r]
+		if !ok && compatible {
+			d, ok = compatibility[r]
+		}
+		if !ok {
+			return []rune{r}
+		}
+		var runes []rune
+		for _, r := range d {
+			runes = append(runes, decompose(r, compatible)...)
+		}
+		return runes
+	}
+	decompositions := map[rune]string{}
+	compatibilities := map[rune]string{}
+	compositions := map[[2]rune]rune{}
+	for r := rune(0); r <= 0x10ffff; r++ {
+		if hangul(r) {
+			continue
+		}
+		nfd, nfkd := string(decompose(r, false)), string(decompose(r, true))
+		if nfd != string(r) {
+			decompositions[r] = nfd
+		}
+		if nfkd != nfd {
+			compatibilities[r] = nfkd
+		}
+		if d := canonical[r]; len(d) == 2 && !excluded[r] {
+			compositions[[2]rune{d[0], d[1]}] = r
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by maketables.go from the Unicode Character Database %s. DO NOT EDIT.\n\n", version)
+	fmt.Fprintf(&b, "package memdb\n\n")
+	fmt.Fprintf(&b, "// decompositions maps precomposed runes to their full canonical decomposition, Hangul\n// syllables are decomposed algorithmically.\n")
+	fmt.Fprintf(&b, "var decompositions = map[rune]string{\n")
+	for _, r := range keys(decompositions) {
+		fmt.Fprintf(&b, "%s: %s,\n", strconv.QuoteRuneToASCII(r), strconv.QuoteToASCII(decompositions[r]))
+	}
+	fmt.Fprintf(&b, "}\n\n// compatibilities maps runes to their full compatibility decomposition where it differs\n// from the canonical one.\n")
+	fmt.Fprintf(&b, "var compatibilities = map[rune]string{\n")
+	for _, r := range keys(compatibilities) {
+		fmt.Fprintf(&b, "%s: %s,\n", strconv.QuoteRuneToASCII(r), strconv.QuoteToASCII(compatibilities[r]))
+	}
+	fmt.Fprintf(&b, "}\n\n// compositions maps a starter and a combining mark to the primary composite.\n")
+	fmt.Fprintf(&b, "var compositions = map[[2]rune]rune{\n")
+	pairs := make([][2]rune, 0, len(compositions))
+	for pair := range compositions {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(a, b int) bool {
+		return compositions[pairs[a]] < compositions[pairs[b]]
+	})
+	for _, pair := range pairs {
+		fmt.Fprintf(&b, "{%s, %s}: %s,\n", strconv.QuoteRuneToASCII(pair[0]), strconv.QuoteRuneToASCII(pair[1]), strconv.QuoteRuneToASCII(compositions[pair]))
+	}
+	fmt.Fprintf(&b, "}\n\n// combiningClasses holds the canonical combining class of combining marks.\n")
+	fmt.Fprintf(&b, "var combiningClasses = map[rune]uint8{\n")
+	for _, r := range keys(classes) {
+		fmt.Fprintf(&b, "%s: %d,\n", strconv.QuoteRuneToASCII(r), classes[r])
+	}
+	fmt.Fprintf(&b, "}\n\n// foldings maps runes to their full case folding, the C and F mappings of CaseFolding.txt.\n")
+	fmt.Fprintf(&b, "var foldings = map[rune]string{\n")
+	for _, r := range keys(foldings) {
+		fmt.Fprintf(&b, "%s: %s,\n", strconv.QuoteRuneToASCII(r), strconv.QuoteToASCII(foldings[r]))
+	}
+	fmt.Fprintf(&b, "}\n")
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = os.WriteFile(*output, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parse calls f with the fields of every data line of the UCD file name.
+func parse(name string, f func(fields []string)) {
+	var r io.ReadCloser
+	if strings.HasPrefix(*ucd, "http://") || strings.HasPrefix(*ucd, "https://") {
+		resp, err := http.Get(strings.TrimSuffix(*ucd, "/") + "/" + name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			log.Fatalf("%s: %s", name, resp.Status)
+		}
+		r = resp.Body
+	} else {
+		file, err := os.Open(filepath.Join(*ucd, name))
+		if err != nil {
+			log.Fatal(err)
+		}
+		r = file
+	}
+	defer r.Close()
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if n := strings.IndexByte(line, '#'); n >= 0 {
+			line = line[:n]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		for n := range fields {
+			fields[n] = strings.TrimSpace(fields[n])
+		}
+		f(fields)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func code(s string) rune {
+	r, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return rune(r)
+}
+
+func codes(s string) []rune {
+	var runes []rune
+	for _, f := range strings.Fields(s) {
+		runes = append(runes, code(f))
+	}
+	return runes
+}
+
+func keys(m interface{}) []rune {
+	var runes []rune
+	switch m := m.(type) {
+	case map[rune]string:
+		for r := range m {
+			runes = append(runes, r)
+		}
+	case map[rune]uint8:
+		for r := range m {
+			runes = append(runes, r)
+		}
+	}
+	sort.Slice(runes, func(a, b int) bool {
+		return runes[a] < runes[b]
+	})
+	return runes
+}
+
+const (
+	sBase, lBase, vBase, tBase = 0xac00, 0x1100, 0x1161, 0x11a7
+	tCount, nCount, sCount     = 28, 21 * 28, 19 * 21 * 28
+)
+
+func hangul(r rune) bool {
+	return r >= sBase && r < sBase+sCount
+}
+
+func hangulDecompose(r rune) []rune {
+	s := r - sBase
+	runes := []rune{lBase + s/nCount, vBase + s%nCount/tCount}
+	if t := s % tCount; t != 0 {
+		runes = append(runes, tBase+t)
+	}
+	return runes
+}
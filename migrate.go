@@ -0,0 +1,183 @@
+package memdb
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+var ErrMigration = errors.New("memdb: migration failed")
+
+// Migration upgrades an item of schema version From to version From+1.
+type Migration struct {
+	From    int
+	Migrate func(Item) (Item, error)
+}
+
+// MigrationStatus tells how far the items of a collection are from its schema version.
+// Pending counts the committed items of older versions, Migrated and Failed count upgrades
+// since the version was set, Running tells if a Migrate pass is running.
+type MigrationStatus struct {
+	Version  int
+	Pending  int64
+	Migrated int64
+	Failed   int64
+	Running  bool
+}
+
+// AddMigration registers migrations, a later migration from the same version replaces one.
+func (c *Collection) AddMigration(migrations ...Migration) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if c.upgrades == nil {
+		c.upgrades = make(map[int32]func(Item) (Item, error))
+	}
+	for _, m := range migrations {
+		c.upgrades[int32(m.From)] = m.Migrate
+	}
+}
+
+// SetVersion sets the schema version of the collection, items written from now on are of this
+// version. Items of older versions are upgraded when they are read or by Migrate, so there
+// must be migrations from the oldest version stored up to the new one.
+func (c *Collection) SetVersion(version int) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if int32(version) < c.version {
+		return fmt.Errorf("%w: version %d is older than %d", ErrMigration, version, c.version)
+	}
+	var pending int64
+	oldest := int32(version)
+	c.Indexes[0].Range(func(_, value interface{}) bool {
+		row := value.(*Row)
+		row.rw.RLock()
+		if row.cas > 0 && row.version < int32(version) {
+			pending++
+			if row.version < oldest {
+				oldest = row.version
+			}
+		}
+		row.rw.RUnlock()
+		return true
+	})
+	for v := oldest; v < int32(version); v++ {
+		if c.upgrades[v] == nil {
+			return fmt.Errorf("%w: no migration from version %d", ErrMigration, v)
+		}
+	}
+	atomic.StoreInt32(&c.version, int32(version))
+	atomic.StoreInt64(&c.pending, pending)
+	atomic.StoreInt64(&c.migrated, 0)
+	atomic.StoreInt64(&c.failed, 0)
+	return nil
+}
+
+// Version returns the schema version of the collection.
+func (c *Collection) Version() int {
+	return int(atomic.LoadInt32(&c.version))
+}
+
+// MigrationStatus returns the progress of upgrading the items to the schema version.
+func (c *Collection) MigrationStatus() MigrationStatus {
+	return MigrationStatus{
+		Version:  int(atomic.LoadInt32(&c.version)),
+		Pending:  atomic.LoadInt64(&c.pending),
+		Migrated: atomic.LoadInt64(&c.migrated),
+		Failed:   atomic.LoadInt64(&c.failed),
+		Running:  atomic.LoadInt32(&c.running) > 0,
+	}
+}
+
+// Migrate upgrades every item of an older version and returns the first error, items which
+// fail stay at their version. It may run in the background while the collection is in use.
+func (c *Collection) Migrate(tx *Tx) error {
+	atomic.AddInt32(&c.running, 1)
+	defer atomic.AddInt32(&c.running, -1)
+	var first error
+	c.index(0).Range(func(_, value interface{}) bool {
+		row := value.(*Row)
+		item, cas, version, ok := row.versioned(tx)
+		if ok && version < atomic.LoadInt32(&c.version) {
+			if _, err := c.upgrade(tx, row, item, cas, version); err != nil && first == nil {
+				first = err
+			}
+		}
+		return true
+	})
+	return first
+}
+
+// migrate applies the migrations from version to the current one, the caller holds the lock.
+func (c *Collection) migrate(item Item, version int32) (Item, error) {
+	for v := version; v < c.version; v++ {
+		m := c.upgrades[v]
+		if m == nil {
+			return nil, fmt.Errorf("%w: no migration from version %d", ErrMigration, v)
+		}
+		var err error
+		if item, err = m(item); err != nil {
+			return nil, fmt.Errorf("%w: from version %d: %v", ErrMigration, v, err)
+		}
+	}
+	return item, nil
+}
+
+// upgrade stores the item of the row upgraded to the current version and updates the indexes
+// of changed fields, the cas of the row is kept. The upgraded item is checked like a written
+// one, when it can't be stored it is still returned.
+func (c *Collection) upgrade(tx *Tx, row *Row, item Item, cas uint64, version int32) (Item, error) {
+	defer c.rlock()()
+	for {
+		upgraded, err := c.migrate(item, version)
+		if err == nil && c.Indexes[0].Key(upgraded) != c.Indexes[0].Key(item) {
+			err = fmt.Errorf("%w: from version %d: primary key changed", ErrMigration, version)
+		}
+		if err != nil {
+			c.fail(row)
+			return item, err
+		}
+		stored, err := c.revise(tx, row, upgraded, cas)
+		if err != nil {
+			c.fail(row)
+			return upgraded, err
+		}
+		if stored {
+			atomic.AddInt64(&c.migrated, 1)
+			return upgraded, nil
+		}
+		var ok bool
+		if item, cas, version, ok = row.versioned(tx); !ok {
+			return upgraded, nil
+		}
+		if version >= c.version {
+			return item, nil
+		}
+	}
+}
+
+// revise stores the upgraded item unless the row changed since cas, the caller holds the read
+// locks of the linked collections.
+func (c *Collection) revise(tx *Tx, row *Row, item Item, cas uint64) (bool, error) {
+	if err := c.validate(item); err != nil {
+		return false, err
+	}
+	unlock, err := c.check(tx, item)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	row.lock(tx)
+	defer row.unlock(tx)
+	if row.cas != cas {
+		return false, nil
+	}
+	_, err = c.replace(tx, row, item, cas)
+	return err == nil, err
+}
+
+// fail counts the failed upgrade of the row once for the current version.
+func (c *Collection) fail(row *Row) {
+	if atomic.SwapInt32(&row.failed, c.version) != c.version {
+		atomic.AddInt64(&c.failed, 1)
+	}
+}
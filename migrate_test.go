@@ -0,0 +1,93 @@
+package memdb
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollection_Migrate(t *testing.T) {
+	collection := newIndexed(
+		Index{
+			Field:   []string{"type"},
+			Mapper:  &NonUniqueIndex{},
+			Indexer: Format,
+		},
+	)
+	for id := 1; id <= 3; id++ {
+		_, err := collection.Store(&Tx{}, Adapt(map[string]interface{}{"id": id, "type": "a"}), 0)
+		require.NoError(t, err)
+	}
+	require.ErrorIs(t, collection.SetVersion(1), ErrMigration)
+	collection.AddMigration(Migration{From: 0, Migrate: func(item Item) (Item, error) {
+		item, _ = item.(Adapter).Set("type", "audio")
+		return item, nil
+	}})
+	require.NoError(t, collection.SetVersion(1))
+	require.ErrorIs(t, collection.SetVersion(0), ErrMigration)
+	require.Equal(t, MigrationStatus{Version: 1, Pending: 3}, collection.MigrationStatus())
+
+	row := collection.Indexes[0].Get(collection.Indexes[0].Index(1))[0]
+	cas := row.cas
+	item := collection.Get(&Tx{}, 0, []interface{}{1})
+	require.Equal(t, "audio", item[0].Field("type"))
+	require.Equal(t, cas, row.cas)
+	require.Equal(t, MigrationStatus{Version: 1, Pending: 2, Migrated: 1}, collection.MigrationStatus())
+	require.Len(t, collection.Get(&Tx{}, 1, []interface{}{"audio"}), 1)
+	for _, item := range collection.Get(&Tx{}, 1, []interface{}{"a"}) {
+		require.Equal(t, "audio", item.Field("type"))
+	}
+
+	_, err := collection.Store(&Tx{}, Adapt(map[string]interface{}{"id": 4, "type": "video"}), 0)
+	require.NoError(t, err)
+	require.NoError(t, collection.Migrate(&Tx{}))
+	require.Equal(t, MigrationStatus{Version: 1, Migrated: 3}, collection.MigrationStatus())
+	require.Len(t, collection.Get(&Tx{}, 1, []interface{}{"audio"}), 3)
+	require.Empty(t, collection.Get(&Tx{}, 1, []interface{}{"a"}))
+	require.Empty(t, collection.Verify(&Tx{}, false))
+
+	collection.AddMigration(Migration{From: 1, Migrate: func(item Item) (Item, error) {
+		if item.Field("type") == "video" {
+			return nil, errors.New("video")
+		}
+		item, _ = item.(Adapter).Set("type", "sound")
+		return item, nil
+	}})
+	require.NoError(t, collection.SetVersion(2))
+	require.ErrorIs(t, collection.Migrate(&Tx{}), ErrMigration)
+	require.Equal(t, MigrationStatus{Version: 2, Pending: 1, Migrated: 3, Failed: 1}, collection.MigrationStatus())
+	require.Len(t, collection.Get(&Tx{}, 1, []interface{}{"sound"}), 3)
+	require.Len(t, collection.Get(&Tx{}, 0, []interface{}{4}), 1)
+	require.ErrorIs(t, collection.Migrate(&Tx{}), ErrMigration)
+	require.Equal(t, MigrationStatus{Version: 2, Pending: 1, Migrated: 3, Failed: 1}, collection.MigrationStatus())
+	_, err = collection.Remove(&Tx{}, Adapt(map[string]interface{}{"id": 4, "type": "video"}), 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), collection.MigrationStatus().Pending)
+}
+
+func TestCollection_Migrate_checks(t *testing.T) {
+	collection := newIndexed()
+	collection.AddRule(Required("name"))
+	collection.AddImmutable("login")
+	for id, login := range []string{"a", "b"} {
+		_, err := collection.Store(&Tx{}, Adapt(map[string]interface{}{"id": id, "login": login, "name": login}), 0)
+		require.NoError(t, err)
+	}
+	collection.AddMigration(Migration{From: 0, Migrate: func(item Item) (Item, error) {
+		if item.Field("login") == "a" {
+			item, _ = item.(Adapter).Set("name", nil)
+		} else {
+			item, _ = item.(Adapter).Set("login", "c")
+		}
+		return item, nil
+	}})
+	require.NoError(t, collection.SetVersion(1))
+	require.Error(t, collection.Migrate(&Tx{}))
+	require.Equal(t, MigrationStatus{Version: 1, Pending: 2, Failed: 2}, collection.MigrationStatus())
+	_, err := collection.upgrade(&Tx{}, collection.Indexes[0].Get(collection.Indexes[0].Index(0))[0], Adapt(map[string]interface{}{"id": 0, "login": "a", "name": "a"}), 1, 0)
+	require.ErrorIs(t, err, ErrValidation)
+	_, err = collection.upgrade(&Tx{}, collection.Indexes[0].Get(collection.Indexes[0].Index(1))[0], Adapt(map[string]interface{}{"id": 1, "login": "b", "name": "b"}), 1, 0)
+	require.ErrorIs(t, err, ErrImmutable)
+	require.Equal(t, MigrationStatus{Version: 1, Pending: 2, Failed: 2}, collection.MigrationStatus())
+}
@@ -1,15 +1,24 @@
 package memdb
 
-import "sync"
+import (
+	"sync"
+	"sync/atomic"
+)
 
 type NonUniqueIndex struct {
 	x sync.Map
+	counter
+}
+
+type bucket struct {
+	sync.Map
+	n int64
 }
 
 func (i *NonUniqueIndex) Load(key interface{}) (values []interface{}, ok bool) {
 	u, ok := i.x.Load(key)
 	if ok {
-		u.(*sync.Map).Range(func(_, value interface{}) bool {
+		u.(*bucket).Range(func(_, value interface{}) bool {
 			values = append(values, value)
 			return true
 		})
@@ -18,21 +27,29 @@ func (i *NonUniqueIndex) Load(key interface{}) (values []interface{}, ok bool) {
 }
 
 func (i *NonUniqueIndex) LoadOrStore(key, value interface{}) (interface{}, bool) {
-	u, _ := i.x.LoadOrStore(key, &sync.Map{})
-	return u.(*sync.Map).LoadOrStore(value, value)
+	u, _ := i.x.LoadOrStore(key, &bucket{})
+	v, ok := u.(*bucket).LoadOrStore(value, value)
+	if !ok {
+		i.add(key, atomic.AddInt64(&u.(*bucket).n, 1))
+	}
+	return v, ok
 }
 
 func (i *NonUniqueIndex) LoadAndDelete(key, value interface{}) (interface{}, bool) {
 	u, ok := i.x.Load(key)
 	if ok {
-		return u.(*sync.Map).LoadAndDelete(value)
+		v, ok := u.(*bucket).LoadAndDelete(value)
+		if ok {
+			i.remove(key, atomic.AddInt64(&u.(*bucket).n, -1))
+		}
+		return v, ok
 	}
 	return nil, false
 }
 
 func (i *NonUniqueIndex) Range(f func(key, value interface{}) bool) {
 	i.x.Range(func(key, u interface{}) bool {
-		u.(*sync.Map).Range(func(_, value interface{}) bool {
+		u.(*bucket).Range(func(_, value interface{}) bool {
 			return f(key, value)
 		})
 		return true
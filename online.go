@@ -0,0 +1,119 @@
+package memdb
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+var (
+	ErrConflict = errors.New("memdb: unique index conflict")
+	ErrPrimary  = errors.New("memdb: primary index cannot be dropped")
+	ErrNoIndex  = errors.New("memdb: no such index")
+)
+
+// building is an index being backfilled, writers keep it up to date but never fail on it.
+type building struct {
+	Index
+	conflict int32
+}
+
+func (b *building) put(tx *Tx, key string, row *Row) bool {
+index:
+	one, ok := b.Put(key, row)
+	if ok {
+		if one != row {
+			if one.committed(tx) {
+				atomic.StoreInt32(&b.conflict, 1)
+				return false
+			}
+			goto index
+		}
+		return false
+	}
+	return true
+}
+
+// AddIndex builds the index from the rows of a live collection and publishes it once every row
+// is indexed. It returns the position of the new index or ErrConflict if two rows share a key
+// of a unique index, the mapper of the index is emptied then.
+func (c *Collection) AddIndex(tx *Tx, index Index) (int, error) {
+	b := &building{Index: index}
+	c.mx.Lock()
+	if c.closed {
+		c.mx.Unlock()
+		return 0, ErrClosed
+	}
+	c.building = append(c.building, b)
+	primary := c.Indexes[0]
+	c.mx.Unlock()
+	primary.Range(func(_, value interface{}) bool {
+		row := value.(*Row)
+		if row.read(tx) {
+			if item := row.item(); item != nil && row.cas > 0 {
+				if _, ok := index.Mapper.(*BitmapIndex); ok {
+					c.ordinals.acquire(row)
+				}
+				if key, ok := b.key(item, row); ok {
+					b.put(tx, key, row)
+				}
+			}
+			row.unread(tx)
+		}
+		return atomic.LoadInt32(&b.conflict) == 0
+	})
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	for n := range c.building {
+		if c.building[n] == b {
+			c.building = append(c.building[:n:n], c.building[n+1:]...)
+			break
+		}
+	}
+	if atomic.LoadInt32(&b.conflict) != 0 {
+		empty(index.Mapper)
+		return 0, ErrConflict
+	}
+	c.Indexes = append(c.Indexes[:len(c.Indexes):len(c.Indexes)], index)
+	return len(c.Indexes) - 1, nil
+}
+
+// Indices returns the indexes of the collection, unlike the Indexes field it is safe to call
+// while indexes are added or dropped.
+func (c *Collection) Indices() []Index {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	return append([]Index(nil), c.Indexes...)
+}
+
+// DropIndex removes the secondary index at position i, the indexes after it shift down. An
+// index referenced by a foreign key, or followed by one, is not dropped since foreign keys
+// refer to indexes by position.
+func (c *Collection) DropIndex(i int) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if i == 0 {
+		return ErrPrimary
+	}
+	if i < 0 || i >= len(c.Indexes) {
+		return ErrNoIndex
+	}
+	for _, r := range c.referrer {
+		if i <= r.Index {
+			return fmt.Errorf("%w: foreign key %q references index %d", ErrSchema, r.Name, r.Index)
+		}
+	}
+	c.Indexes = append(c.Indexes[:i:i], c.Indexes[i+1:]...)
+	return nil
+}
+
+func empty(m Mapper) {
+	var keys, values []interface{}
+	m.Range(func(key, value interface{}) bool {
+		keys, values = append(keys, key), append(values, value)
+		return true
+	})
+	for n := range keys {
+		m.LoadAndDelete(keys[n], values[n])
+	}
+}
@@ -0,0 +1,66 @@
+package memdb
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollection_AddIndex(t *testing.T) {
+	collection := newCollection(t)
+	var items []Item
+	for i := 0; i < 100; i++ {
+		item := X1{ID: uuid.New(), Type: "online", Code: i, Name: i}
+		_, ok := collection.Put(&Tx{}, item, 0)
+		require.True(t, ok)
+		items = append(items, item)
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 100; i < 200; i++ {
+			collection.Put(&Tx{}, X1{ID: uuid.New(), Type: "online", Code: i, Name: i}, 0)
+		}
+		for _, item := range items[:50] {
+			collection.Delete(&Tx{}, item, 0)
+		}
+	}()
+	i, err := collection.AddIndex(&Tx{}, Index{
+		Field:   []string{"name"},
+		Mapper:  &UniqueIndex{},
+		Indexer: Format,
+	})
+	wg.Wait()
+	require.NoError(t, err)
+	require.Equal(t, 4, i)
+	for n := 0; n < 200; n++ {
+		got := collection.Get(&Tx{}, i, []interface{}{n})
+		if n < 50 {
+			require.Empty(t, got)
+		} else {
+			require.Len(t, got, 1)
+		}
+	}
+	conflicting := &UniqueIndex{}
+	_, err = collection.AddIndex(&Tx{}, Index{
+		Field:   []string{"type"},
+		Mapper:  conflicting,
+		Indexer: Format,
+	})
+	require.ErrorIs(t, err, ErrConflict)
+	conflicting.Range(func(key, value interface{}) bool {
+		t.Fatal("index not emptied", key)
+		return false
+	})
+	require.Len(t, collection.Indices(), 5)
+	require.ErrorIs(t, collection.DropIndex(0), ErrPrimary)
+	require.ErrorIs(t, collection.DropIndex(5), ErrNoIndex)
+	require.NoError(t, collection.DropIndex(2))
+	require.Len(t, collection.Indices(), 4)
+	require.Len(t, collection.Get(&Tx{}, 3, []interface{}{150}), 1)
+	_, ok := collection.Put(&Tx{}, X1{ID: uuid.New(), Type: "online", Code: 150, Name: 250}, 0)
+	require.True(t, ok)
+}
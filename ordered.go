@@ -0,0 +1,192 @@
+package memdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var ErrEncoding = errors.New("memdb: malformed ordered key")
+
+// Tags of the ordered encoding, values of different types sort by tag.
+const (
+	tagNil byte = iota + 1
+	tagFalse
+	tagTrue
+	tagInt
+	tagUint
+	tagFloat
+	tagString
+	tagTime
+	tagOther
+)
+
+// Ordered is an Indexer which encodes values so that keys sort like the tuples of values.
+// Every value is self-delimiting, so the key of leading values is a prefix of the full key.
+func Ordered(values ...interface{}) string {
+	var b strings.Builder
+	for _, value := range values {
+		encode(&b, value)
+	}
+	return b.String()
+}
+
+// OrderedPrefix returns the ordered encoding of strings starting with s, it follows the key
+// of leading values.
+func OrderedPrefix(s string) string {
+	var b strings.Builder
+	b.WriteByte(tagString)
+	escape(&b, s)
+	return b.String()
+}
+
+func encode(b *strings.Builder, value interface{}) {
+	var buf [8]byte
+	switch v := value.(type) {
+	case nil:
+		b.WriteByte(tagNil)
+		return
+	case bool:
+		if v {
+			b.WriteByte(tagTrue)
+		} else {
+			b.WriteByte(tagFalse)
+		}
+		return
+	case string:
+		b.WriteByte(tagString)
+		escape(b, v)
+		b.WriteString("\x00\x01")
+		return
+	case []byte:
+		b.WriteByte(tagString)
+		escape(b, string(v))
+		b.WriteString("\x00\x01")
+		return
+	case time.Time:
+		b.WriteByte(tagTime)
+		binary.BigEndian.PutUint64(buf[:], uint64(v.UnixNano())^1<<63)
+		b.Write(buf[:])
+		return
+	case fmt.Stringer:
+		b.WriteByte(tagOther)
+		escape(b, v.String())
+		b.WriteString("\x00\x01")
+		return
+	}
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		b.WriteByte(tagInt)
+		binary.BigEndian.PutUint64(buf[:], uint64(v.Int())^1<<63)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() <= math.MaxInt64 {
+			b.WriteByte(tagInt)
+			binary.BigEndian.PutUint64(buf[:], v.Uint()^1<<63)
+		} else {
+			b.WriteByte(tagUint)
+			binary.BigEndian.PutUint64(buf[:], v.Uint())
+		}
+	case reflect.Float32, reflect.Float64:
+		b.WriteByte(tagFloat)
+		bits := math.Float64bits(v.Float())
+		if bits&(1<<63) != 0 {
+			bits = ^bits
+		} else {
+			bits |= 1 << 63
+		}
+		binary.BigEndian.PutUint64(buf[:], bits)
+	case reflect.String:
+		encode(b, v.String())
+		return
+	case reflect.Bool:
+		encode(b, v.Bool())
+		return
+	default:
+		b.WriteByte(tagOther)
+		escape(b, fmt.Sprint(value))
+		b.WriteString("\x00\x01")
+		return
+	}
+	b.Write(buf[:])
+}
+
+// escape writes s with zero bytes doubled up as 0x00 0xFF, the terminator 0x00 0x01 sorts first.
+func escape(b *strings.Builder, s string) {
+	for i := 0; i < len(s); i++ {
+		b.WriteByte(s[i])
+		if s[i] == 0 {
+			b.WriteByte(0xFF)
+		}
+	}
+}
+
+// Decode returns the values of a key made by Ordered, descending fields are recognized by
+// their complemented tag. Integers decode as int64 or uint64, floats as float64, strings,
+// byte slices and other values as string and times as UTC time.Time.
+func Decode(key string) ([]interface{}, error) {
+	var values []interface{}
+	for len(key) > 0 {
+		var mask byte
+		if key[0] > 0x7F {
+			mask = 0xFF
+		}
+		tag := key[0] ^ mask
+		key = key[1:]
+		switch tag {
+		case tagNil:
+			values = append(values, nil)
+		case tagFalse, tagTrue:
+			values = append(values, tag == tagTrue)
+		case tagInt, tagUint, tagFloat, tagTime:
+			if len(key) < 8 {
+				return nil, ErrEncoding
+			}
+			var buf [8]byte
+			for n := range buf {
+				buf[n] = key[n] ^ mask
+			}
+			key = key[8:]
+			u := binary.BigEndian.Uint64(buf[:])
+			switch tag {
+			case tagInt:
+				values = append(values, int64(u^1<<63))
+			case tagUint:
+				values = append(values, u)
+			case tagFloat:
+				if u&(1<<63) != 0 {
+					u &^= 1 << 63
+				} else {
+					u = ^u
+				}
+				values = append(values, math.Float64frombits(u))
+			case tagTime:
+				values = append(values, time.Unix(0, int64(u^1<<63)).UTC())
+			}
+		case tagString, tagOther:
+			var b strings.Builder
+			for {
+				if len(key) < 2 {
+					return nil, ErrEncoding
+				}
+				c := key[0] ^ mask
+				if c == 0 {
+					if key[1]^mask == 1 {
+						key = key[2:]
+						break
+					}
+					key = key[1:]
+				}
+				b.WriteByte(c)
+				key = key[1:]
+			}
+			values = append(values, b.String())
+		default:
+			return nil, ErrEncoding
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,253 @@
+package memdb
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+)
+
+const maxLevel = 24
+
+// Scanner is implemented by mappers which iterate their keys in order.
+type Scanner interface {
+	Scan(prefix string, f func(key, value interface{}) bool)
+}
+
+// Seeker is implemented by mappers which iterate their keys in order from a given key.
+type Seeker interface {
+	Seek(after string, f func(key, value interface{}) bool)
+}
+
+// OrderedIndex keeps keys sorted in a skip list, use it with the Ordered indexer to query
+// by leading fields of a composite index. Keys sort bytewise unless Compare is set, which
+// orders keys by their decoded values, keys it finds equal still sort bytewise. With Compare
+// set a prefix scan visits every key.
+type OrderedIndex struct {
+	Unique  bool
+	Compare func(a, b []interface{}) int
+	mx      sync.RWMutex
+	head    node
+	level   int
+	random  *rand.Rand
+	counter
+}
+
+type node struct {
+	key    string
+	values []interface{}
+	fields []interface{}
+	next   []*node
+}
+
+// Prefix returns items of the ordered index i whose leading fields equal values, in key order.
+func (c *Collection) Prefix(tx *Tx, i int, values ...interface{}) []Item {
+	index := c.index(i)
+	return c.scan(tx, index, index.Index(values...))
+}
+
+// StartsWith returns items of the ordered index i whose leading fields equal values and whose
+// next field is a string starting with s, in key order.
+func (c *Collection) StartsWith(tx *Tx, i int, s string, values ...interface{}) []Item {
+	index := c.index(i)
+	if index.Collation != 0 {
+		s = index.Collation.Collate(s)
+	}
+	prefix := OrderedPrefix(s)
+	if index.direction(len(values)) == Desc {
+		prefix = invert(prefix)
+	}
+	return c.scan(tx, index, index.Index(values...)+prefix)
+}
+
+// Page returns up to limit items of the ordered index i following the cursor, an empty cursor
+// starts from the first key. Rows sharing a key are never split between pages, so a page of
+// a non-unique index may hold more items. The returned cursor is empty after the last page.
+func (c *Collection) Page(tx *Tx, i int, cursor string, limit int) ([]Item, string) {
+	var rows []interface{}
+	var last string
+	more := false
+	c.index(i).Mapper.(Seeker).Seek(cursor, func(key, value interface{}) bool {
+		if len(rows) >= limit && key.(string) != last {
+			more = true
+			return false
+		}
+		rows, last = append(rows, value), key.(string)
+		return true
+	})
+	if !more {
+		last = ""
+	}
+	return c.items(tx, rows), last
+}
+
+func (c *Collection) scan(tx *Tx, index Index, prefix string) []Item {
+	var rows []interface{}
+	index.Mapper.(Scanner).Scan(prefix, func(_, value interface{}) bool {
+		rows = append(rows, value)
+		return true
+	})
+	return c.items(tx, rows)
+}
+
+func (i *OrderedIndex) decode(key string) []interface{} {
+	if i.Compare == nil {
+		return nil
+	}
+	fields, _ := Decode(key)
+	return fields
+}
+
+func (i *OrderedIndex) less(x *node, key string, fields []interface{}) bool {
+	if i.Compare != nil {
+		if c := i.Compare(x.fields, fields); c != 0 {
+			return c < 0
+		}
+	}
+	return x.key < key
+}
+
+// find returns the first node with a key not less than key and fills update with its predecessors.
+func (i *OrderedIndex) find(key string, update []*node) *node {
+	fields := i.decode(key)
+	x := &i.head
+	for level := i.level - 1; level >= 0; level-- {
+		for x.next[level] != nil && i.less(x.next[level], key, fields) {
+			x = x.next[level]
+		}
+		if update != nil {
+			update[level] = x
+		}
+	}
+	if i.level == 0 {
+		return nil
+	}
+	return x.next[0]
+}
+
+func (i *OrderedIndex) Load(key interface{}) ([]interface{}, bool) {
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	x := i.find(key.(string), nil)
+	if x == nil || x.key != key.(string) {
+		return nil, false
+	}
+	return append([]interface{}(nil), x.values...), true
+}
+
+func (i *OrderedIndex) LoadOrStore(key, value interface{}) (interface{}, bool) {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	if i.head.next == nil {
+		i.head.next = make([]*node, maxLevel)
+		i.random = rand.New(rand.NewSource(rand.Int63()))
+	}
+	var update [maxLevel]*node
+	x := i.find(key.(string), update[:])
+	if x == nil || x.key != key.(string) {
+		level := 1
+		for level < maxLevel && i.random.Intn(4) == 0 {
+			level++
+		}
+		for ; i.level < level; i.level++ {
+			update[i.level] = &i.head
+		}
+		x = &node{key: key.(string), fields: i.decode(key.(string)), next: make([]*node, level)}
+		for l := 0; l < level; l++ {
+			x.next[l], update[l].next[l] = update[l].next[l], x
+		}
+	}
+	for _, v := range x.values {
+		if v == value || i.Unique {
+			return v, true
+		}
+	}
+	x.values = append(x.values, value)
+	i.add(key, int64(len(x.values)))
+	return value, false
+}
+
+func (i *OrderedIndex) LoadAndDelete(key, value interface{}) (interface{}, bool) {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	var update [maxLevel]*node
+	x := i.find(key.(string), update[:])
+	if x == nil || x.key != key.(string) {
+		return nil, false
+	}
+	for n, v := range x.values {
+		if v != value {
+			continue
+		}
+		x.values = append(x.values[:n], x.values[n+1:]...)
+		i.remove(key, int64(len(x.values)))
+		if len(x.values) == 0 {
+			for l := 0; l < len(x.next); l++ {
+				update[l].next[l] = x.next[l]
+			}
+			for i.level > 0 && i.head.next[i.level-1] == nil {
+				i.level--
+			}
+		}
+		return v, true
+	}
+	return nil, false
+}
+
+func (i *OrderedIndex) Range(f func(key, value interface{}) bool) {
+	i.Scan("", f)
+}
+
+// Scan calls f for every value with a key starting with prefix in key order.
+func (i *OrderedIndex) Scan(prefix string, f func(key, value interface{}) bool) {
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	if i.Compare != nil {
+		i.walk(i.head.next, func(key string) bool {
+			return !strings.HasPrefix(key, prefix)
+		}, f)
+		return
+	}
+	for x := i.find(prefix, nil); x != nil && strings.HasPrefix(x.key, prefix); x = x.next[0] {
+		for _, v := range x.values {
+			if !f(x.key, v) {
+				return
+			}
+		}
+	}
+}
+
+// Seek calls f for every value with a key following after in key order.
+func (i *OrderedIndex) Seek(after string, f func(key, value interface{}) bool) {
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	if i.level == 0 {
+		return
+	}
+	next := i.head.next
+	if after != "" {
+		if x := i.find(after, nil); x != nil {
+			next = []*node{x}
+		} else {
+			return
+		}
+	}
+	i.walk(next, func(key string) bool {
+		return key == after
+	}, f)
+}
+
+func (i *OrderedIndex) walk(next []*node, skip func(string) bool, f func(key, value interface{}) bool) {
+	if len(next) == 0 {
+		return
+	}
+	for x := next[0]; x != nil; x = x.next[0] {
+		if skip(x.key) {
+			continue
+		}
+		for _, v := range x.values {
+			if !f(x.key, v) {
+				return
+			}
+		}
+	}
+}
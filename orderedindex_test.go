@@ -0,0 +1,114 @@
+package memdb
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func newOrderedCollection(t testing.TB, items ...Item) *Collection {
+	t.Helper()
+	collection := newIndexed(
+		Index{
+			Field:   []string{"type", "name"},
+			Mapper:  &OrderedIndex{Unique: true},
+			Indexer: Ordered,
+		},
+	)
+	for _, item := range items {
+		_, ok := collection.Put(&Tx{}, item, 0)
+		require.True(t, ok)
+	}
+	return collection
+}
+
+func TestCollection_Prefix(t *testing.T) {
+	item := []Item{
+		X1{ID: uuid.New(), Type: "audio", Name: 2},
+		X1{ID: uuid.New(), Type: "audio", Name: -1},
+		X1{ID: uuid.New(), Type: "audiobook", Name: 1},
+		X1{ID: uuid.New(), Type: "video", Name: 1},
+		X1{ID: uuid.New(), Type: "audio", Name: 10},
+	}
+	collection := newOrderedCollection(t, item...)
+	_, ok := collection.Put(&Tx{}, X1{ID: uuid.New(), Type: "audio", Name: 2}, 0)
+	require.False(t, ok)
+	require.Equal(t, []Item{item[1], item[0], item[4]}, collection.Prefix(&Tx{}, 1, "audio"))
+	require.Equal(t, []Item{item[0]}, collection.Prefix(&Tx{}, 1, "audio", 2))
+	require.Equal(t, []Item{item[1], item[0], item[4], item[2]}, collection.StartsWith(&Tx{}, 1, "aud"))
+	require.Equal(t, []Item{item[2]}, collection.StartsWith(&Tx{}, 1, "audiob"))
+	require.Equal(t, []Item{item[1], item[0], item[4], item[2], item[3]}, collection.Prefix(&Tx{}, 1))
+	require.Equal(t, []Item{item[3]}, collection.Get(&Tx{}, 1, []interface{}{"video", 1}))
+	_, ok = collection.Delete(&Tx{}, item[0], 0)
+	require.True(t, ok)
+	_, ok = collection.Put(&Tx{}, X1{ID: item[3].(X1).ID, Type: "audio", Name: 0}, 0)
+	require.True(t, ok)
+	require.Len(t, collection.Prefix(&Tx{}, 1, "audio"), 3)
+	require.Empty(t, collection.StartsWith(&Tx{}, 1, "v"))
+}
+
+func TestCollection_Page(t *testing.T) {
+	collection := newIndexed(
+		Index{
+			Field:   []string{"code", "type"},
+			Mapper:  &OrderedIndex{Unique: true},
+			Indexer: Ordered,
+			Order:   []Direction{Desc, Asc},
+		},
+		Index{
+			Field: []string{"code", "type"},
+			Mapper: &OrderedIndex{Compare: func(a, b []interface{}) int {
+				x, y := a[0].(int64), b[0].(int64)
+				if x < 0 {
+					x = -x
+				}
+				if y < 0 {
+					y = -y
+				}
+				return int(x - y)
+			}},
+			Indexer: Ordered,
+			Order:   []Direction{Asc, Desc},
+		},
+	)
+	item := []Item{
+		X1{ID: uuid.New(), Type: "bob", Code: 10},
+		X1{ID: uuid.New(), Type: "ann", Code: 30},
+		X1{ID: uuid.New(), Type: "cid", Code: 10},
+		X1{ID: uuid.New(), Type: "dan", Code: -20},
+		X1{ID: uuid.New(), Type: "eve", Code: 5},
+	}
+	for _, x := range item {
+		_, ok := collection.Put(&Tx{}, x, 0)
+		require.True(t, ok)
+	}
+	var pages [][]Item
+	for cursor := ""; ; {
+		var page []Item
+		page, cursor = collection.Page(&Tx{}, 1, cursor, 2)
+		pages = append(pages, page)
+		if cursor == "" {
+			break
+		}
+	}
+	require.Equal(t, [][]Item{{item[1], item[0]}, {item[2], item[4]}, {item[3]}}, pages)
+	require.Equal(t, []Item{item[0], item[2]}, collection.Prefix(&Tx{}, 1, 10))
+	require.Equal(t, []Item{item[2]}, collection.StartsWith(&Tx{}, 1, "c", 10))
+	page, cursor := collection.Page(&Tx{}, 2, "", 10)
+	require.Equal(t, []Item{item[4], item[2], item[0], item[3], item[1]}, page)
+	require.Empty(t, cursor)
+	page, cursor = collection.Page(&Tx{}, 2, "", 5)
+	require.Len(t, page, 5)
+	require.Empty(t, cursor)
+	require.Equal(t, []Item{item[3]}, collection.Prefix(&Tx{}, 2, -20))
+}
+
+func TestDecode(t *testing.T) {
+	index := Index{Indexer: Ordered, Order: []Direction{Asc, Desc, Desc, Asc, Desc}}
+	values, err := Decode(index.Index(-5, "a\x00b", 2.5, true, nil))
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{int64(-5), "a\x00b", 2.5, true, nil}, values)
+	_, err = Decode("\x07abc")
+	require.ErrorIs(t, err, ErrEncoding)
+}
@@ -4,14 +4,39 @@ import "sync"
 
 type Tx struct {
 	tx *Tx
+	db *Database
 }
 
 type Row struct {
 	Item
-	cas uint64
-	rw  sync.RWMutex
-	mx  sync.Mutex
-	tx  *Tx
+	cas     uint64
+	ord     uint32
+	version int32
+	rw      sync.RWMutex
+	mx      sync.Mutex
+	tx      *Tx
+	pager   pager
+	page    uint32
+	failed  int32
+}
+
+// pager keeps the items of rows out of memory, the caller holds the row lock.
+type pager interface {
+	save(row *Row, item Item) error
+	load(row *Row) (Item, error)
+	drop(row *Row)
+}
+
+// item returns the item of the row, it is loaded by the pager of a row which has one.
+func (r *Row) item() Item {
+	if r.pager != nil && r.page != 0 {
+		item, err := r.pager.load(r)
+		if err != nil {
+			return nil
+		}
+		return item
+	}
+	return r.Item
 }
 
 func (r *Row) acquire(t *Tx) bool {
@@ -74,9 +99,19 @@ func (r *Row) committed(tx *Tx) bool {
 func (r *Row) get(tx *Tx) (Item, uint64, bool) {
 	if r.read(tx) {
 		defer r.unread(tx)
-		if r.Item != nil && r.cas > 0 {
-			return r.Item, r.cas, true
+		if item := r.item(); item != nil && r.cas > 0 {
+			return item, r.cas, true
 		}
 	}
 	return nil, 0, false
 }
+
+func (r *Row) versioned(tx *Tx) (Item, uint64, int32, bool) {
+	if r.read(tx) {
+		defer r.unread(tx)
+		if item := r.item(); item != nil && r.cas > 0 {
+			return item, r.cas, r.version, true
+		}
+	}
+	return nil, 0, 0, false
+}
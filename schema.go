@@ -0,0 +1,222 @@
+package memdb
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var ErrSchema = errors.New("memdb: invalid schema")
+
+// Schema declares a collection of items of Type.
+type Schema struct {
+	Name      string
+	Type      reflect.Type
+	Indexes   []IndexSchema
+	Immutable []string
+}
+
+// IndexSchema declares an index over fields, the first index of a schema is the primary one.
+type IndexSchema struct {
+	Name   string
+	Field  []string
+	Unique bool
+}
+
+// SchemaOf derives the schema of a struct from the memdb tags of its fields, which are named
+// by their json tags. A tag holds options separated by semicolons, each of them one of pk,
+// unique or index followed by an optional group, so `memdb:"unique,group=type_name"` on two
+// fields declares a composite unique index named type_name. Fields of a group are indexed in
+// the order of declaration, fields tagged pk make the primary index. The option immutable
+// declares a field which never changes once the item is stored.
+func SchemaOf(v interface{}) (Schema, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return Schema{}, fmt.Errorf("%w: %v is not a struct", ErrSchema, t)
+	}
+	s := Schema{Name: t.Name(), Type: t}
+	kinds := map[string]string{}
+	var names []string
+	groups := map[string]*IndexSchema{}
+	for n := 0; n < t.NumField(); n++ {
+		f := t.Field(n)
+		tag, ok := f.Tag.Lookup("memdb")
+		if !ok || tag == "" || tag == "-" {
+			continue
+		}
+		name := fieldName(f)
+		if name == "" || f.PkgPath != "" {
+			return Schema{}, fmt.Errorf("%w: %v.%s is not stored", ErrSchema, t, f.Name)
+		}
+		for _, option := range strings.Split(tag, ";") {
+			parts := strings.Split(option, ",")
+			kind, group := strings.TrimSpace(parts[0]), name
+			switch kind {
+			case "immutable":
+				if len(parts) > 1 {
+					return Schema{}, fmt.Errorf("%w: %v.%s: unknown option %q", ErrSchema, t, f.Name, option)
+				}
+				s.Immutable = append(s.Immutable, name)
+				continue
+			case "pk", "unique", "index":
+			default:
+				return Schema{}, fmt.Errorf("%w: %v.%s: unknown option %q", ErrSchema, t, f.Name, kind)
+			}
+			for _, part := range parts[1:] {
+				key, value := part, ""
+				if n := strings.IndexByte(part, '='); n >= 0 {
+					key, value = strings.TrimSpace(part[:n]), strings.TrimSpace(part[n+1:])
+				}
+				if key != "group" || value == "" {
+					return Schema{}, fmt.Errorf("%w: %v.%s: unknown option %q", ErrSchema, t, f.Name, part)
+				}
+				group = value
+			}
+			if kind == "pk" {
+				group = ""
+			}
+			index, ok := groups[group]
+			if !ok {
+				index = &IndexSchema{Name: group, Unique: kind != "index"}
+				groups[group] = index
+				kinds[group] = kind
+				names = append(names, group)
+			} else if kinds[group] != kind {
+				return Schema{}, fmt.Errorf("%w: %v.%s: group %q is both %s and %s", ErrSchema, t, f.Name, group, kinds[group], kind)
+			}
+			index.Field = append(index.Field, name)
+		}
+	}
+	primary, ok := groups[""]
+	if !ok {
+		return Schema{}, fmt.Errorf("%w: %v has no pk field", ErrSchema, t)
+	}
+	primary.Name = "pk"
+	s.Indexes = append(s.Indexes, *primary)
+	for _, name := range names {
+		if name != "" {
+			s.Indexes = append(s.Indexes, *groups[name])
+		}
+	}
+	return s, nil
+}
+
+// fieldName returns the json name of a struct field, empty if it is not marshalled.
+func fieldName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return f.Name
+	}
+	return name
+}
+
+// NewCollection returns an empty collection with the indexes and immutable fields of the
+// schema. It checks that the first index is unique, that every index has fields and a distinct
+// name if any, and, when Type is set, that the type has every indexed and immutable field.
+// Types implementing Item are asked for the fields of their zero value, other types are
+// checked like Adapter resolves fields.
+func NewCollection(s Schema) (*Collection, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	c := &Collection{}
+	for _, index := range s.Indexes {
+		var mapper Mapper = &NonUniqueIndex{}
+		if index.Unique {
+			mapper = &UniqueIndex{}
+		}
+		c.Indexes = append(c.Indexes, Index{
+			Field:   append([]string(nil), index.Field...),
+			Mapper:  mapper,
+			Indexer: Format,
+		})
+	}
+	c.immutable = append([]string(nil), s.Immutable...)
+	return c, nil
+}
+
+// CollectionOf returns an empty collection with the schema derived from the struct v.
+func CollectionOf(v interface{}) (*Collection, error) {
+	s, err := SchemaOf(v)
+	if err != nil {
+		return nil, err
+	}
+	return NewCollection(s)
+}
+
+func (s Schema) validate() error {
+	if len(s.Indexes) == 0 {
+		return fmt.Errorf("%w: %s has no indexes", ErrSchema, s.Name)
+	}
+	if !s.Indexes[0].Unique {
+		return fmt.Errorf("%w: %s: primary index %q is not unique", ErrSchema, s.Name, s.Indexes[0].Name)
+	}
+	names := map[string]int{}
+	for n, index := range s.Indexes {
+		if index.Name != "" {
+			if m, ok := names[index.Name]; ok {
+				return fmt.Errorf("%w: %s: indexes %d and %d are both named %q", ErrSchema, s.Name, m, n, index.Name)
+			}
+			names[index.Name] = n
+		}
+		if len(index.Field) == 0 {
+			return fmt.Errorf("%w: %s: index %d %q has no fields", ErrSchema, s.Name, n, index.Name)
+		}
+		seen := map[string]bool{}
+		for _, f := range index.Field {
+			if seen[f] {
+				return fmt.Errorf("%w: %s: index %d %q repeats field %q", ErrSchema, s.Name, n, index.Name, f)
+			}
+			seen[f] = true
+			if s.Type != nil && !hasField(s.Type, f) {
+				return fmt.Errorf("%w: %s: index %d %q: %v has no field %q", ErrSchema, s.Name, n, index.Name, s.Type, f)
+			}
+		}
+	}
+	for _, f := range s.Immutable {
+		if f == "" || s.Type != nil && !hasField(s.Type, f) {
+			return fmt.Errorf("%w: %s: immutable: %v has no field %q", ErrSchema, s.Name, s.Type, f)
+		}
+	}
+	return nil
+}
+
+// hasField tells if items of type t have the field name.
+func hasField(t reflect.Type, name string) (ok bool) {
+	if item, is := reflect.Zero(t).Interface().(Item); is && t.Kind() != reflect.Ptr {
+		defer func() {
+			if recover() != nil {
+				ok = false
+			}
+		}()
+		item.Field(name)
+		return true
+	}
+	for _, part := range strings.Split(name, ".") {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Map:
+			return t.Key().Kind() == reflect.String
+		case reflect.Interface:
+			return true
+		case reflect.Struct:
+			index, ok := fieldsOf(t)[part]
+			if !ok {
+				return false
+			}
+			t = t.FieldByIndex(index).Type
+		default:
+			return false
+		}
+	}
+	return true
+}
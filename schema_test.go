@@ -0,0 +1,85 @@
+package memdb
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaOf(t *testing.T) {
+	schema, err := SchemaOf(&X1{})
+	require.NoError(t, err)
+	require.Equal(t, Schema{
+		Name: "X1",
+		Type: reflect.TypeOf(X1{}),
+		Indexes: []IndexSchema{
+			{Name: "pk", Field: []string{"id"}, Unique: true},
+			{Name: "type_name", Field: []string{"type", "name"}, Unique: true},
+			{Name: "code", Field: []string{"code"}, Unique: true},
+			{Name: "time", Field: []string{"time"}},
+		},
+	}, schema)
+
+	collection, err := CollectionOf(X1{})
+	require.NoError(t, err)
+	expected := newCollection(t)
+	require.Len(t, collection.Indexes, len(expected.Indexes))
+	for n, index := range collection.Indexes {
+		require.Equal(t, expected.Indexes[n].Field, index.Field)
+		require.IsType(t, expected.Indexes[n].Mapper, index.Mapper)
+	}
+
+	for _, v := range []interface{}{
+		1,
+		struct {
+			ID int `memdb:"index"`
+		}{},
+		struct {
+			ID int `memdb:"primary"`
+		}{},
+		struct {
+			ID int `memdb:"pk,size=1"`
+		}{},
+		struct {
+			ID   int `memdb:"pk"`
+			A, B int `memdb:"unique,group=ab"`
+			C    int `memdb:"index,group=ab"`
+		}{},
+		struct {
+			ID int `json:"-" memdb:"pk"`
+		}{},
+	} {
+		_, err := SchemaOf(v)
+		require.ErrorIs(t, err, ErrSchema)
+	}
+}
+
+func TestNewCollection(t *testing.T) {
+	collection, err := NewCollection(Schema{
+		Type: reflect.TypeOf(A1{}),
+		Indexes: []IndexSchema{
+			{Field: []string{"id"}, Unique: true},
+			{Field: []string{"address.city"}},
+			{Field: []string{"Version", "name"}},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, collection.Indexes, 3)
+	_, ok := collection.Put(&Tx{}, Adapt(A1{ID: 1}), 0)
+	require.True(t, ok)
+
+	for _, s := range []Schema{
+		{Name: "empty"},
+		{Indexes: []IndexSchema{{Name: "pk", Field: []string{"id"}}}},
+		{Indexes: []IndexSchema{{Name: "pk", Unique: true}}},
+		{Indexes: []IndexSchema{{Name: "pk", Field: []string{"id", "id"}, Unique: true}}},
+		{Indexes: []IndexSchema{{Name: "pk", Field: []string{"id"}, Unique: true}, {Name: "pk", Field: []string{"code"}}}},
+		{Type: reflect.TypeOf(X1{}), Indexes: []IndexSchema{{Field: []string{"id"}, Unique: true}, {Field: []string{"kind"}}}},
+		{Type: reflect.TypeOf(A1{}), Indexes: []IndexSchema{{Field: []string{"address.zip"}, Unique: true}}},
+		{Type: reflect.TypeOf(A1{}), Indexes: []IndexSchema{{Field: []string{"id.value"}, Unique: true}}},
+	} {
+		_, err := NewCollection(s)
+		require.ErrorIs(t, err, ErrSchema, s)
+	}
+}
@@ -0,0 +1,272 @@
+package memdb
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrDuplicate = errors.New("memdb: item already exists")
+
+// Generator makes primary keys for Insert.
+type Generator interface {
+	Generate() interface{}
+}
+
+// GeneratorFunc is a Generator of a function.
+type GeneratorFunc func() interface{}
+
+func (f GeneratorFunc) Generate() interface{} {
+	return f()
+}
+
+// Sequence hands out increasing uint64 values from Start, 1 by default. Without Cache it is
+// gap-free, values given back by Release are handed out again first, and Insert gives back the
+// values of items it fails to store. With Cache values are reserved in blocks of Cache and
+// never reused, so a failed insert leaves a gap.
+type Sequence struct {
+	Start uint64
+	Cache uint64
+	mx    sync.Mutex
+	next  uint64
+	limit uint64
+	free  []uint64
+}
+
+// Sequence returns the sequence named name, it is created on first use.
+func (d *Database) Sequence(name string) *Sequence {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	if d.sequences == nil {
+		d.sequences = make(map[string]*Sequence)
+	}
+	s, ok := d.sequences[name]
+	if !ok {
+		s = &Sequence{}
+		d.sequences[name] = s
+	}
+	return s
+}
+
+// Next returns the next value of the sequence.
+func (s *Sequence) Next() uint64 {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.next == 0 {
+		s.next = s.Start
+		if s.next == 0 {
+			s.next = 1
+		}
+		s.limit = s.next
+	}
+	if len(s.free) > 0 {
+		v := s.free[0]
+		s.free = s.free[1:]
+		return v
+	}
+	if s.Cache > 0 && s.next == s.limit {
+		s.limit += s.Cache
+	}
+	s.next++
+	return s.next - 1
+}
+
+// Reserved returns the value following the last block reserved, or the next value without Cache.
+func (s *Sequence) Reserved() uint64 {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.Cache > 0 {
+		return s.limit
+	}
+	return s.next
+}
+
+// Release gives back an unused value of a gap-free sequence.
+func (s *Sequence) Release(v uint64) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.Cache > 0 || v >= s.next {
+		return
+	}
+	n := sort.Search(len(s.free), func(n int) bool {
+		return s.free[n] >= v
+	})
+	if n < len(s.free) && s.free[n] == v {
+		return
+	}
+	s.free = append(s.free, 0)
+	copy(s.free[n+1:], s.free[n:])
+	s.free[n] = v
+}
+
+func (s *Sequence) Generate() interface{} {
+	return s.Next()
+}
+
+// UUIDv4 returns a Generator of random UUIDs.
+func UUIDv4() Generator {
+	return GeneratorFunc(func() interface{} {
+		return uuid.New()
+	})
+}
+
+// UUIDv7 returns a Generator of UUIDs ordered by the time they were made in milliseconds, the
+// UUIDs of a millisecond are ordered by a counter starting at a random value.
+func UUIDv7() Generator {
+	var mx sync.Mutex
+	var last, counter uint64
+	return GeneratorFunc(func() interface{} {
+		var u uuid.UUID
+		random(u[:])
+		mx.Lock()
+		ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+		if ms > last {
+			last, counter = ms, uint64(u[6]&0x07)<<8|uint64(u[7])
+		} else if counter++; counter > 0xfff {
+			last, counter = last+1, 0
+		}
+		ms, c := last, counter
+		mx.Unlock()
+		binary.BigEndian.PutUint64(u[:8], ms<<16|0x7000|c)
+		u[8] = u[8]&0x3f | 0x80
+		return u
+	})
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID returns a Generator of ULID strings, the ULIDs of a millisecond increase by one.
+func ULID() Generator {
+	var mx sync.Mutex
+	var last uint64
+	var entropy [10]byte
+	return GeneratorFunc(func() interface{} {
+		mx.Lock()
+		defer mx.Unlock()
+		ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+		if ms > last {
+			last = ms
+			random(entropy[:])
+		} else {
+			for n := len(entropy) - 1; n >= 0; n-- {
+				if entropy[n]++; entropy[n] != 0 {
+					break
+				}
+			}
+		}
+		var id [16]byte
+		binary.BigEndian.PutUint64(id[:8], last<<16)
+		copy(id[6:], entropy[:])
+		var b strings.Builder
+		b.WriteByte(crockford[id[0]>>5])
+		acc, bits := uint(id[0]&0x1f), 5
+		for n := 1; bits >= 5 || n < len(id); {
+			for bits < 5 {
+				acc, bits, n = acc<<8|uint(id[n]), bits+8, n+1
+			}
+			bits -= 5
+			b.WriteByte(crockford[acc>>uint(bits)&0x1f])
+			acc &= 1<<uint(bits) - 1
+		}
+		return b.String()
+	})
+}
+
+// Snowflake returns a Generator of int64 IDs made of 41 bits of milliseconds since 2020, 10
+// bits of the node and 12 bits of a counter, so that nodes never make the same ID.
+func Snowflake(node int64) Generator {
+	epoch := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var mx sync.Mutex
+	var last, counter int64
+	return GeneratorFunc(func() interface{} {
+		mx.Lock()
+		defer mx.Unlock()
+		ms := time.Since(epoch).Milliseconds()
+		if ms > last {
+			last, counter = ms, 0
+		} else if counter++; counter > 0xfff {
+			for ms <= last {
+				time.Sleep(time.Millisecond)
+				ms = time.Since(epoch).Milliseconds()
+			}
+			last, counter = ms, 0
+		}
+		return last<<22 | node&0x3ff<<12 | counter
+	})
+}
+
+func random(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+}
+
+// Insert stores a new item and returns it with its cas. When the single field of the primary
+// index is nil or zero the Generator fills it in, which takes an item implementing Setter.
+func (c *Collection) Insert(tx *Tx, item Item) (Item, uint64, error) {
+	if tx.closed() {
+		return nil, 0, ErrClosed
+	}
+	defer c.rlock()()
+	if c.closed {
+		return nil, 0, ErrClosed
+	}
+	var generated interface{}
+	giveBack := func() {
+		if s, ok := c.Generator.(*Sequence); ok && generated != nil {
+			s.Release(generated.(uint64))
+		}
+	}
+	if field := c.Indexes[0].Field; len(field) == 1 && c.Generator != nil {
+		if value := item.Field(field[0]); isNil(value) || reflect.ValueOf(value).IsZero() {
+			setter, ok := item.(Setter)
+			if ok {
+				generated = c.Generator.Generate()
+				item, ok = setter.Set(field[0], generated)
+			}
+			if !ok {
+				giveBack()
+				return nil, 0, fmt.Errorf("%w: cannot set %q of %T", ErrRejected, field[0], item)
+			}
+		}
+	}
+	cas, err := c.insertItem(tx, item)
+	if err != nil {
+		giveBack()
+		return nil, 0, err
+	}
+	return item, cas, nil
+}
+
+func (c *Collection) insertItem(tx *Tx, item Item) (uint64, error) {
+	if err := c.fault(); err != nil {
+		return 0, err
+	}
+	if err := c.validate(item); err != nil {
+		return 0, err
+	}
+	unlock, err := c.check(tx, item)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	cas, err := c.put(tx, item, 0, true)
+	if err := c.fault(); err != nil {
+		return 0, err
+	}
+	if err != nil {
+		if rows := c.Indexes[0].Get(c.Indexes[0].Key(item)); len(rows) > 0 && rows[0].committed(tx) {
+			return 0, ErrDuplicate
+		}
+		return 0, err
+	}
+	return cas, nil
+}
@@ -0,0 +1,82 @@
+package memdb
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSequence(t *testing.T) {
+	var db Database
+	orders := db.Sequence("orders")
+	require.Same(t, orders, db.Sequence("orders"))
+	require.Equal(t, uint64(1), orders.Next())
+	require.Equal(t, uint64(2), orders.Next())
+	require.Equal(t, uint64(3), orders.Next())
+	orders.Release(2)
+	orders.Release(5)
+	require.Equal(t, uint64(2), orders.Next())
+	require.Equal(t, uint64(4), orders.Next())
+	require.Equal(t, uint64(5), orders.Reserved())
+
+	cached := &Sequence{Start: 100, Cache: 10}
+	require.Equal(t, uint64(100), cached.Next())
+	require.Equal(t, uint64(110), cached.Reserved())
+	cached.Release(100)
+	for n := 0; n < 10; n++ {
+		cached.Next()
+	}
+	require.Equal(t, uint64(120), cached.Reserved())
+}
+
+func TestGenerator(t *testing.T) {
+	v7, ulid, snowflake := UUIDv7(), ULID(), Snowflake(3)
+	var lastUUID uuid.UUID
+	var lastULID string
+	var lastSnowflake int64
+	for n := 0; n < 10000; n++ {
+		u := v7.Generate().(uuid.UUID)
+		require.Equal(t, uuid.Version(7), u.Version())
+		require.Equal(t, uuid.RFC4122, u.Variant())
+		require.Greater(t, u.String(), lastUUID.String())
+		lastUUID = u
+
+		s := ulid.Generate().(string)
+		require.Regexp(t, regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`), s)
+		require.Greater(t, s, lastULID)
+		lastULID = s
+
+		id := snowflake.Generate().(int64)
+		require.Greater(t, id, lastSnowflake)
+		require.Equal(t, int64(3), id>>12&0x3ff)
+		lastSnowflake = id
+	}
+	require.Equal(t, uuid.Version(4), UUIDv4().Generate().(uuid.UUID).Version())
+}
+
+func TestCollection_Insert(t *testing.T) {
+	collection := newIndexed(Index{Field: []string{"name"}, Mapper: &UniqueIndex{}, Indexer: Format})
+	collection.Generator = &Sequence{}
+	item, _, err := collection.Insert(&Tx{}, Adapt(A1{Name: "one"}))
+	require.NoError(t, err)
+	require.Equal(t, 1, item.Field("id"))
+	_, _, err = collection.Insert(&Tx{}, Adapt(A1{Name: "one"}))
+	require.ErrorIs(t, err, ErrRejected)
+	item, _, err = collection.Insert(&Tx{}, Adapt(A1{Name: "two"}))
+	require.NoError(t, err)
+	require.Equal(t, 2, item.Field("id"))
+	_, _, err = collection.Insert(&Tx{}, Adapt(A1{ID: 2, Name: "three"}))
+	require.ErrorIs(t, err, ErrDuplicate)
+	require.Len(t, collection.Get(&Tx{}, 1, []interface{}{"two"}), 1)
+	_, _, err = collection.Insert(&Tx{}, X1{Type: "x"})
+	require.Error(t, err)
+
+	collection = newCollection(t)
+	collection.Generator = UUIDv7()
+	_, _, err = collection.Insert(&Tx{}, X1{Type: "x"})
+	require.ErrorIs(t, err, ErrRejected)
+	_, _, err = collection.Insert(&Tx{}, X1{ID: uuid.New(), Type: "x"})
+	require.NoError(t, err)
+}
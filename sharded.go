@@ -0,0 +1,145 @@
+package memdb
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ShardedIndex spreads keys over lock-striped shards of plain maps. It suits write-heavy
+// workloads better than the sync.Map based indexes. Every key maps to a set of rows, with
+// Unique set the row already in the set is loaded instead of adding another.
+type ShardedIndex struct {
+	Shards int
+	Hash   func(string) uint32
+	Unique bool
+	once   sync.Once
+	shards []shard
+}
+
+type shard struct {
+	mx   sync.RWMutex
+	keys map[interface{}]map[interface{}]struct{}
+	counter
+}
+
+func (i *ShardedIndex) shard(key interface{}) *shard {
+	i.once.Do(func() {
+		n := i.Shards
+		if n < 1 {
+			n = 32
+		}
+		i.shards = make([]shard, n)
+		for k := range i.shards {
+			i.shards[k].keys = make(map[interface{}]map[interface{}]struct{})
+		}
+		if i.Hash == nil {
+			i.Hash = fnv32a
+		}
+	})
+	s, ok := key.(string)
+	if !ok {
+		s = fmt.Sprint(key)
+	}
+	return &i.shards[i.Hash(s)%uint32(len(i.shards))]
+}
+
+func (i *ShardedIndex) Load(key interface{}) (values []interface{}, ok bool) {
+	s := i.shard(key)
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	set, ok := s.keys[key]
+	for value := range set {
+		values = append(values, value)
+	}
+	return
+}
+
+func (i *ShardedIndex) LoadOrStore(key, value interface{}) (interface{}, bool) {
+	s := i.shard(key)
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	set, ok := s.keys[key]
+	if !ok {
+		set = make(map[interface{}]struct{}, 1)
+		s.keys[key] = set
+	}
+	if i.Unique {
+		for v := range set {
+			return v, true
+		}
+	} else if _, ok := set[value]; ok {
+		return value, true
+	}
+	set[value] = struct{}{}
+	s.add(key, int64(len(set)))
+	return value, false
+}
+
+func (i *ShardedIndex) LoadAndDelete(key, value interface{}) (interface{}, bool) {
+	s := i.shard(key)
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	set, ok := s.keys[key]
+	if !ok {
+		return nil, false
+	}
+	if i.Unique {
+		for v := range set {
+			value = v
+		}
+	}
+	if _, ok := set[value]; !ok {
+		return nil, false
+	}
+	delete(set, value)
+	if len(set) == 0 {
+		delete(s.keys, key)
+	}
+	s.remove(key, int64(len(set)))
+	return value, true
+}
+
+func (i *ShardedIndex) Range(f func(key, value interface{}) bool) {
+	i.shard("")
+	for k := range i.shards {
+		if !i.shards[k].each(f) {
+			return
+		}
+	}
+}
+
+func (i *ShardedIndex) Stats() (stats Stats) {
+	i.shard("")
+	for k := range i.shards {
+		s := i.shards[k].Stats()
+		stats.Keys += s.Keys
+		stats.Rows += s.Rows
+		stats.Bytes += s.Bytes
+		if s.Max > stats.Max {
+			stats.Max = s.Max
+		}
+	}
+	return
+}
+
+func (s *shard) each(f func(key, value interface{}) bool) bool {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	for key, set := range s.keys {
+		for value := range set {
+			if !f(key, value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func fnv32a(s string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(s[i])
+		h *= 16777619
+	}
+	return h
+}
@@ -0,0 +1,118 @@
+package memdb
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardedIndex(t *testing.T) {
+	collection := &Collection{
+		Indexes: []Index{
+			{
+				Field:   []string{"id"},
+				Mapper:  &ShardedIndex{Unique: true, Shards: 4},
+				Indexer: Format,
+			}, {
+				Field:   []string{"code"},
+				Mapper:  &ShardedIndex{Unique: true},
+				Indexer: Format,
+			}, {
+				Field:   []string{"type"},
+				Mapper:  &ShardedIndex{},
+				Indexer: Format,
+			},
+		},
+	}
+	var items []Item
+	for i := 0; i < 100; i++ {
+		item := X1{ID: uuid.New(), Type: strconv.Itoa(i % 10), Code: i}
+		_, ok := collection.Put(&Tx{}, item, 0)
+		require.True(t, ok)
+		items = append(items, item)
+	}
+	_, ok := collection.Put(&Tx{}, X1{ID: uuid.New(), Code: 1}, 0)
+	require.False(t, ok)
+	require.Equal(t, []Item{items[5]}, collection.Get(&Tx{}, 1, []interface{}{5}))
+	require.Len(t, collection.Get(&Tx{}, 2, []interface{}{"5"}), 10)
+	for _, item := range items[:50] {
+		_, ok := collection.Delete(&Tx{}, item, 0)
+		require.True(t, ok)
+	}
+	require.Len(t, collection.Get(&Tx{}, 2, []interface{}{"5"}), 5)
+	require.Equal(t, []Stats{
+		{Keys: 50, Rows: 50, Max: 1, Bytes: collection.Stats()[0].Bytes},
+		{Keys: 50, Rows: 50, Max: 1, Bytes: collection.Stats()[1].Bytes},
+		{Keys: 10, Rows: 50, Max: 5, Bytes: collection.Stats()[2].Bytes},
+	}, collection.Stats())
+	require.Empty(t, collection.Verify(&Tx{}, false))
+}
+
+func benchmarkMapper(b *testing.B, mapper Mapper, keys int) {
+	var n int64
+	b.RunParallel(func(pb *testing.PB) {
+		row := &Row{}
+		for pb.Next() {
+			key := Format(atomic.AddInt64(&n, 1) % int64(keys))
+			mapper.LoadOrStore(key, row)
+			mapper.Load(key)
+			mapper.LoadAndDelete(key, row)
+		}
+	})
+}
+
+func BenchmarkMapper(b *testing.B) {
+	for _, bm := range []struct {
+		name   string
+		mapper func() Mapper
+	}{
+		{"UniqueIndex", func() Mapper { return &UniqueIndex{} }},
+		{"ShardedIndex/unique", func() Mapper { return &ShardedIndex{Unique: true} }},
+		{"NonUniqueIndex", func() Mapper { return &NonUniqueIndex{} }},
+		{"ShardedIndex/non-unique", func() Mapper { return &ShardedIndex{} }},
+	} {
+		for _, keys := range []int{16, 1 << 16} {
+			b.Run(bm.name+"/"+strconv.Itoa(keys), func(b *testing.B) {
+				benchmarkMapper(b, bm.mapper(), keys)
+			})
+		}
+	}
+}
+
+func BenchmarkCollection_Put_sharded(b *testing.B) {
+	collection := &Collection{
+		Indexes: []Index{
+			{
+				Field:   []string{"id"},
+				Mapper:  &ShardedIndex{Unique: true},
+				Indexer: Format,
+			}, {
+				Field:   []string{"type", "name"},
+				Mapper:  &ShardedIndex{Unique: true},
+				Indexer: Format,
+			}, {
+				Field:   []string{"code"},
+				Mapper:  &ShardedIndex{Unique: true},
+				Indexer: Format,
+			}, {
+				Field:   []string{"time"},
+				Mapper:  &ShardedIndex{},
+				Indexer: Format,
+			},
+		},
+	}
+	for i := 0; i < b.N; i++ {
+		cas, ok := collection.Put(&Tx{}, X1{
+			ID:   uuid.UUID{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, byte(i >> 24), byte(i >> 16), byte(i >> 8), byte(i)},
+			Type: "audio",
+			Code: i,
+			Name: i,
+		}, 0)
+		if !ok || cas == 0 {
+			b.FailNow()
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package memdb
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+	"sync"
+)
+
+// Sketch estimates the number of distinct keys of an index and its most frequent keys without
+// scanning it. The distinct count is a HyperLogLog whose registers keep counts per rank, and
+// frequencies come from a count-min sketch, so both follow deletes as well as inserts.
+type Sketch struct {
+	Precision uint8
+	Width     int
+	Depth     int
+	Top       int
+	mx        sync.Mutex
+	ranks     [][32]uint32
+	counts    [][]int64
+	top       map[string]int64
+}
+
+// Frequency is an estimated number of rows stored under a key.
+type Frequency struct {
+	Key   string
+	Count int64
+}
+
+func (s *Sketch) init() {
+	if s.ranks != nil {
+		return
+	}
+	if s.Precision < 4 || s.Precision > 16 {
+		s.Precision = 10
+	}
+	if s.Width < 1 {
+		s.Width = 1024
+	}
+	if s.Depth < 1 {
+		s.Depth = 4
+	}
+	if s.Top < 1 {
+		s.Top = 10
+	}
+	s.ranks = make([][32]uint32, 1<<s.Precision)
+	s.counts = make([][]int64, s.Depth)
+	for d := range s.counts {
+		s.counts[d] = make([]int64, s.Width)
+	}
+	s.top = make(map[string]int64)
+}
+
+func (s *Sketch) add(key string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.init()
+	s.update(key, 1)
+}
+
+func (s *Sketch) remove(key string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.init()
+	s.update(key, -1)
+}
+
+func (s *Sketch) update(key string, delta int64) {
+	h := hash64(key)
+	register, rank := h>>(64-s.Precision), bits.LeadingZeros64(h<<s.Precision|1<<(s.Precision-1))+1
+	if rank > 32 {
+		rank = 32
+	}
+	if delta > 0 {
+		s.ranks[register][rank-1]++
+	} else if s.ranks[register][rank-1] > 0 {
+		s.ranks[register][rank-1]--
+	}
+	h1, h2 := uint32(h), uint32(h>>32)|1
+	for d := range s.counts {
+		s.counts[d][(h1+uint32(d)*h2)%uint32(s.Width)] += delta
+	}
+	count := s.estimate(h)
+	if _, ok := s.top[key]; ok || len(s.top) < 4*s.Top {
+		s.top[key] = count
+	} else if delta > 0 {
+		least, min := "", count
+		for k, c := range s.top {
+			if c < min {
+				least, min = k, c
+			}
+		}
+		if least != "" {
+			delete(s.top, least)
+			s.top[key] = count
+		}
+	}
+	if count <= 0 {
+		delete(s.top, key)
+	}
+}
+
+func (s *Sketch) estimate(h uint64) int64 {
+	h1, h2 := uint32(h), uint32(h>>32)|1
+	count := int64(math.MaxInt64)
+	for d := range s.counts {
+		if c := s.counts[d][(h1+uint32(d)*h2)%uint32(s.Width)]; c < count {
+			count = c
+		}
+	}
+	return count
+}
+
+// Distinct returns the estimated number of distinct keys.
+func (s *Sketch) Distinct() uint64 {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.init()
+	m := float64(len(s.ranks))
+	var sum float64
+	var zeros int
+	for _, ranks := range s.ranks {
+		rank := 0
+		for r := len(ranks); r > 0; r-- {
+			if ranks[r-1] > 0 {
+				rank = r
+				break
+			}
+		}
+		if rank == 0 {
+			zeros++
+		}
+		sum += math.Exp2(-float64(rank))
+	}
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+// Count returns the estimated number of rows stored under key.
+func (s *Sketch) Count(key string) int64 {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.init()
+	return s.estimate(hash64(key))
+}
+
+// TopK returns up to k most frequent keys, most frequent first.
+func (s *Sketch) TopK(k int) []Frequency {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.init()
+	top := make([]Frequency, 0, len(s.top))
+	for key := range s.top {
+		top = append(top, Frequency{Key: key, Count: s.estimate(hash64(key))})
+	}
+	sort.Slice(top, func(a, b int) bool {
+		if top[a].Count != top[b].Count {
+			return top[a].Count > top[b].Count
+		}
+		return top[a].Key < top[b].Key
+	})
+	if len(top) > k {
+		top = top[:k]
+	}
+	return top
+}
+
+// hash64 is FNV-1a finished with the SplitMix64 mixer.
+func hash64(s string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= 1099511628211
+	}
+	h = (h ^ h>>30) * 0xbf58476d1ce4e5b9
+	h = (h ^ h>>27) * 0x94d049bb133111eb
+	return h ^ h>>31
+}
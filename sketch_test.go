@@ -0,0 +1,64 @@
+package memdb
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSketch(t *testing.T) {
+	sketch := &Sketch{}
+	for i := 0; i < 20000; i++ {
+		sketch.add(Format(i))
+	}
+	require.InEpsilon(t, 20000, float64(sketch.Distinct()), 0.1)
+	for i := 0; i < 15000; i++ {
+		sketch.remove(Format(i))
+	}
+	require.InEpsilon(t, 5000, float64(sketch.Distinct()), 0.1)
+	for i := 15000; i < 20000; i++ {
+		sketch.remove(Format(i))
+	}
+	require.Zero(t, sketch.Distinct())
+}
+
+func TestIndex_Sketch(t *testing.T) {
+	collection := newCollection(t)
+	collection.Indexes[3].Sketch = &Sketch{Top: 3}
+	collection.Indexes[2].Sketch = &Sketch{}
+	collection.Indexes = append(collection.Indexes, Index{
+		Field:   []string{"type"},
+		Mapper:  &NonUniqueIndex{},
+		Indexer: Format,
+		Sketch:  &Sketch{Top: 3},
+	})
+	types := []string{"audio", "video", "image", "text", "font"}
+	var items []Item
+	for i := 0; i < 1000; i++ {
+		item := X1{ID: uuid.New(), Type: types[i%(i%5+1)], Code: i, Name: i}
+		_, ok := collection.Put(&Tx{}, item, 0)
+		require.True(t, ok)
+		items = append(items, item)
+	}
+	require.InEpsilon(t, 1000, float64(collection.Indexes[2].Sketch.Distinct()), 0.1)
+	require.Equal(t, uint64(5), collection.Indexes[4].Sketch.Distinct())
+	top := collection.Indexes[4].Sketch.TopK(3)
+	require.Len(t, top, 3)
+	require.Equal(t, Format("audio"), top[0].Key)
+	require.Equal(t, int64(1000), collection.Indexes[4].Sketch.Count(Format("audio"))+
+		collection.Indexes[4].Sketch.Count(Format("video"))+
+		collection.Indexes[4].Sketch.Count(Format("image"))+
+		collection.Indexes[4].Sketch.Count(Format("text"))+
+		collection.Indexes[4].Sketch.Count(Format("font")))
+	for _, item := range items {
+		if item.(X1).Type == "audio" {
+			_, ok := collection.Put(&Tx{}, X1{ID: item.(X1).ID, Type: "video", Code: item.(X1).Code, Name: item.(X1).Name}, 0)
+			require.True(t, ok)
+		}
+	}
+	require.Equal(t, uint64(4), collection.Indexes[4].Sketch.Distinct())
+	require.Equal(t, Format("video"), collection.Indexes[4].Sketch.TopK(1)[0].Key)
+	require.Zero(t, collection.Indexes[4].Sketch.Count(Format("audio")))
+	require.Equal(t, uint64(1), collection.Indexes[3].Sketch.Distinct())
+}
@@ -0,0 +1,132 @@
+package memdb
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Approximate memory taken by a map entry and by a value stored in an index.
+const (
+	keyOverhead = 64
+	rowOverhead = 48
+)
+
+// Stats describes the contents of an index.
+type Stats struct {
+	Keys  int64
+	Rows  int64
+	Max   int64
+	Bytes int64
+}
+
+// Average returns the average number of rows per key.
+func (s Stats) Average() float64 {
+	if s.Keys == 0 {
+		return 0
+	}
+	return float64(s.Rows) / float64(s.Keys)
+}
+
+// Selectivity returns the share of distinct keys among rows, 1 for a unique index.
+func (s Stats) Selectivity() float64 {
+	if s.Rows == 0 {
+		return 0
+	}
+	return float64(s.Keys) / float64(s.Rows)
+}
+
+// Statistics is implemented by mappers which count their contents.
+type Statistics interface {
+	Stats() Stats
+}
+
+// Stats returns the statistics of the index mapper, zero if the mapper does not keep them.
+func (i Index) Stats() Stats {
+	if s, ok := i.Mapper.(Statistics); ok {
+		return s.Stats()
+	}
+	return Stats{}
+}
+
+// Stats returns the statistics of every index in the collection.
+func (c *Collection) Stats() []Stats {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	stats := make([]Stats, 0, len(c.Indexes))
+	for _, index := range c.Indexes {
+		stats = append(stats, index.Stats())
+	}
+	return stats
+}
+
+// counter keeps the statistics of a mapper with atomic counters, so that it adds no lock to
+// the mapper. sizes holds the number of keys per key size, max is raised by add and lowered
+// lazily by Stats.
+type counter struct {
+	keys  int64
+	rows  int64
+	bytes int64
+	max   int64
+	sizes sync.Map
+}
+
+func (c *counter) size(n int64) *int64 {
+	if v, ok := c.sizes.Load(n); ok {
+		return v.(*int64)
+	}
+	v, _ := c.sizes.LoadOrStore(n, new(int64))
+	return v.(*int64)
+}
+
+// add records that a key holds n rows after a row was stored under it.
+func (c *counter) add(key interface{}, n int64) {
+	if n == 1 {
+		atomic.AddInt64(&c.keys, 1)
+		atomic.AddInt64(&c.bytes, keySize(key))
+	}
+	atomic.AddInt64(&c.rows, 1)
+	atomic.AddInt64(&c.bytes, rowOverhead)
+	atomic.AddInt64(c.size(n-1), -1)
+	atomic.AddInt64(c.size(n), 1)
+	for max := atomic.LoadInt64(&c.max); n > max; max = atomic.LoadInt64(&c.max) {
+		if atomic.CompareAndSwapInt64(&c.max, max, n) {
+			break
+		}
+	}
+}
+
+// remove records that a key holds n rows after a row was deleted under it.
+func (c *counter) remove(key interface{}, n int64) {
+	if n == 0 {
+		atomic.AddInt64(&c.keys, -1)
+		atomic.AddInt64(&c.bytes, -keySize(key))
+	}
+	atomic.AddInt64(&c.rows, -1)
+	atomic.AddInt64(&c.bytes, -rowOverhead)
+	atomic.AddInt64(c.size(n+1), -1)
+	atomic.AddInt64(c.size(n), 1)
+}
+
+func (c *counter) Stats() Stats {
+	max := atomic.LoadInt64(&c.max)
+	for max > 0 && atomic.LoadInt64(c.size(max)) <= 0 {
+		if atomic.CompareAndSwapInt64(&c.max, max, max-1) {
+			max--
+		} else {
+			max = atomic.LoadInt64(&c.max)
+		}
+	}
+	return Stats{
+		Keys:  atomic.LoadInt64(&c.keys),
+		Rows:  atomic.LoadInt64(&c.rows),
+		Max:   max,
+		Bytes: atomic.LoadInt64(&c.bytes),
+	}
+}
+
+func keySize(key interface{}) int64 {
+	if s, ok := key.(string); ok {
+		return keyOverhead + int64(len(s))
+	}
+	return keyOverhead
+}
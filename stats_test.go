@@ -0,0 +1,43 @@
+package memdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollection_Stats(t *testing.T) {
+	now := time.Now()
+	var items []Item
+	for i := 0; i < 10; i++ {
+		items = append(items, X1{ID: uuid.New(), Type: "stats", Code: i, Name: i, Time: now.Add(time.Duration(i%3) * time.Hour)})
+	}
+	collection := newCollection(t, items...)
+	stats := collection.Stats()
+	require.Len(t, stats, 4)
+	require.Equal(t, int64(10), stats[0].Keys)
+	require.Equal(t, int64(10), stats[0].Rows)
+	require.Equal(t, int64(1), stats[0].Max)
+	require.Equal(t, 1.0, stats[0].Selectivity())
+	require.Equal(t, int64(3), stats[3].Keys)
+	require.Equal(t, int64(10), stats[3].Rows)
+	require.Equal(t, int64(4), stats[3].Max)
+	require.InDelta(t, 10.0/3, stats[3].Average(), 1e-9)
+	require.Positive(t, stats[3].Bytes)
+	for _, item := range items {
+		if item.(X1).Code%3 == 0 {
+			collection.Delete(&Tx{}, item, 0)
+		}
+	}
+	stats = collection.Stats()
+	require.Equal(t, int64(6), stats[0].Keys)
+	require.Equal(t, int64(2), stats[3].Keys)
+	require.Equal(t, int64(6), stats[3].Rows)
+	require.Equal(t, int64(3), stats[3].Max)
+	for _, item := range items {
+		collection.Delete(&Tx{}, item, 0)
+	}
+	require.Equal(t, []Stats{{}, {}, {}, {}}, collection.Stats())
+}
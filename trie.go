@@ -0,0 +1,255 @@
+package memdb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Text is an Indexer which joins values with spaces, it keeps string keys as they are.
+func Text(values ...interface{}) string {
+	s := make([]string, 0, len(values))
+	for _, value := range values {
+		s = append(s, fmt.Sprint(value))
+	}
+	return strings.Join(s, " ")
+}
+
+// TrieIndex keeps keys in a trie for prefix completion and typo tolerant lookup, use it with
+// the Text indexer. Keys are ranked by the number of rows stored under them.
+type TrieIndex struct {
+	mx   sync.RWMutex
+	root trie
+	counter
+}
+
+type trie struct {
+	children map[rune]*trie
+	rows     map[interface{}]struct{}
+}
+
+type term struct {
+	key  string
+	rows int
+	cost int
+	node *trie
+}
+
+// Complete returns items of up to limit most frequent keys of the trie index i starting
+// with prefix.
+func (c *Collection) Complete(tx *Tx, i int, prefix string, limit int) []Item {
+	index := c.index(i)
+	trie := index.Mapper.(*TrieIndex)
+	return c.items(tx, trie.values(trie.complete(index.Index(prefix), limit)))
+}
+
+// Fuzzy returns items of keys of the trie index i within maxEdits edits of the term, closest
+// and then most frequent first.
+func (c *Collection) Fuzzy(tx *Tx, i int, term string, maxEdits int) []Item {
+	index := c.index(i)
+	trie := index.Mapper.(*TrieIndex)
+	return c.items(tx, trie.values(trie.fuzzy(index.Index(term), maxEdits)))
+}
+
+func (i *TrieIndex) find(key string, create bool) *trie {
+	x := &i.root
+	for _, r := range key {
+		next, ok := x.children[r]
+		if !ok {
+			if !create {
+				return nil
+			}
+			if x.children == nil {
+				x.children = make(map[rune]*trie)
+			}
+			next = &trie{}
+			x.children[r] = next
+		}
+		x = next
+	}
+	return x
+}
+
+func (i *TrieIndex) Load(key interface{}) (values []interface{}, ok bool) {
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	if x := i.find(key.(string), false); x != nil {
+		for value := range x.rows {
+			values = append(values, value)
+		}
+	}
+	return values, len(values) > 0
+}
+
+func (i *TrieIndex) LoadOrStore(key, value interface{}) (interface{}, bool) {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	x := i.find(key.(string), true)
+	if _, ok := x.rows[value]; ok {
+		return value, true
+	}
+	if x.rows == nil {
+		x.rows = make(map[interface{}]struct{})
+	}
+	x.rows[value] = struct{}{}
+	i.add(key, int64(len(x.rows)))
+	return value, false
+}
+
+func (i *TrieIndex) LoadAndDelete(key, value interface{}) (interface{}, bool) {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	x := i.find(key.(string), false)
+	if x == nil {
+		return nil, false
+	}
+	if _, ok := x.rows[value]; !ok {
+		return nil, false
+	}
+	delete(x.rows, value)
+	i.remove(key, int64(len(x.rows)))
+	i.root.prune(key.(string))
+	return value, true
+}
+
+// prune removes the empty nodes along the key and tells if x itself became empty.
+func (x *trie) prune(key string) bool {
+	if key != "" {
+		r, size := utf8.DecodeRuneInString(key)
+		if child, ok := x.children[r]; ok && child.prune(key[size:]) {
+			delete(x.children, r)
+		}
+	}
+	return len(x.rows) == 0 && len(x.children) == 0
+}
+
+func (i *TrieIndex) Range(f func(key, value interface{}) bool) {
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	i.root.walk("", func(key string, x *trie) bool {
+		for value := range x.rows {
+			if !f(key, value) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func (x *trie) walk(key string, f func(string, *trie) bool) bool {
+	if len(x.rows) > 0 && !f(key, x) {
+		return false
+	}
+	for r, child := range x.children {
+		if !child.walk(key+string(r), f) {
+			return false
+		}
+	}
+	return true
+}
+
+// Complete returns up to limit most frequent keys starting with prefix.
+func (i *TrieIndex) Complete(prefix string, limit int) []string {
+	return keys(i.complete(prefix, limit))
+}
+
+// Fuzzy returns the keys within maxEdits insertions, deletions or substitutions of the term,
+// closest and then most frequent first.
+func (i *TrieIndex) Fuzzy(term string, maxEdits int) []string {
+	return keys(i.fuzzy(term, maxEdits))
+}
+
+func (i *TrieIndex) complete(prefix string, limit int) []term {
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	var terms []term
+	if x := i.find(prefix, false); x != nil {
+		x.walk(prefix, func(key string, x *trie) bool {
+			terms = append(terms, term{key: key, rows: len(x.rows), node: x})
+			return true
+		})
+	}
+	return rank(terms, limit)
+}
+
+// fuzzy walks the trie carrying the state of a Levenshtein automaton for the term, which is
+// the row of edit distances between the term prefixes and the path, and leaves branches
+// whose every distance exceeds maxEdits.
+func (i *TrieIndex) fuzzy(s string, maxEdits int) []term {
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	word := []rune(s)
+	state := make([]int, len(word)+1)
+	for n := range state {
+		state[n] = n
+	}
+	var terms []term
+	var step func(key string, x *trie, state []int)
+	step = func(key string, x *trie, state []int) {
+		if cost := state[len(word)]; cost <= maxEdits && len(x.rows) > 0 {
+			terms = append(terms, term{key: key, rows: len(x.rows), cost: cost, node: x})
+		}
+		for r, child := range x.children {
+			next := make([]int, len(state))
+			next[0] = state[0] + 1
+			best := next[0]
+			for n := 1; n < len(next); n++ {
+				cost := 1
+				if word[n-1] == r {
+					cost = 0
+				}
+				next[n] = minInt(minInt(next[n-1]+1, state[n]+1), state[n-1]+cost)
+				best = minInt(best, next[n])
+			}
+			if best <= maxEdits {
+				step(key+string(r), child, next)
+			}
+		}
+	}
+	step("", &i.root, state)
+	return rank(terms, len(terms))
+}
+
+func (i *TrieIndex) values(terms []term) (values []interface{}) {
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	for _, t := range terms {
+		for value := range t.node.rows {
+			values = append(values, value)
+		}
+	}
+	return
+}
+
+func rank(terms []term, limit int) []term {
+	sort.Slice(terms, func(a, b int) bool {
+		if terms[a].cost != terms[b].cost {
+			return terms[a].cost < terms[b].cost
+		}
+		if terms[a].rows != terms[b].rows {
+			return terms[a].rows > terms[b].rows
+		}
+		return terms[a].key < terms[b].key
+	})
+	if len(terms) > limit {
+		terms = terms[:limit]
+	}
+	return terms
+}
+
+func keys(terms []term) []string {
+	keys := make([]string, 0, len(terms))
+	for _, t := range terms {
+		keys = append(keys, t.key)
+	}
+	return keys
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
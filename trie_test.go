@@ -0,0 +1,53 @@
+package memdb
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollection_Complete(t *testing.T) {
+	collection := newIndexed(
+		Index{
+			Field:     []string{"type"},
+			Mapper:    &TrieIndex{},
+			Indexer:   Text,
+			Collation: Fold,
+		},
+	)
+	var items []Item
+	for _, word := range []string{"apple", "Apple", "apply", "apricot", "banana", "band", "bandana", "apple"} {
+		item := X1{ID: uuid.New(), Type: word}
+		_, ok := collection.Put(&Tx{}, item, 0)
+		require.True(t, ok)
+		items = append(items, item)
+	}
+	trie := collection.Indexes[1].Mapper.(*TrieIndex)
+
+	require.Equal(t, []string{"apple", "apply", "apricot"}, trie.Complete("ap", 10))
+	require.Equal(t, []string{"apple", "apply"}, trie.Complete("app", 2))
+	require.Empty(t, trie.Complete("c", 10))
+	require.Len(t, collection.Complete(&Tx{}, 1, "APP", 1), 3)
+
+	require.Equal(t, []string{"band"}, trie.Fuzzy("bend", 1))
+	require.Equal(t, []string{"banana", "bandana", "band"}, trie.Fuzzy("bandna", 2))
+	require.Equal(t, []string{"apple"}, trie.Fuzzy("aple", 1))
+	require.Equal(t, []string{"apple", "apply"}, trie.Fuzzy("aple", 2))
+	require.Equal(t, []string{"bandana"}, trie.Fuzzy("bandana", 0))
+	require.ElementsMatch(t, []Item{items[4]}, collection.Fuzzy(&Tx{}, 1, "Bananna", 1))
+
+	for _, item := range items[:3] {
+		_, ok := collection.Delete(&Tx{}, item, 0)
+		require.True(t, ok)
+	}
+	require.Equal(t, []string{"apple", "apricot"}, trie.Complete("ap", 10))
+	stats := trie.Stats()
+	require.Equal(t, [3]int64{5, 5, 1}, [3]int64{stats.Keys, stats.Rows, stats.Max})
+	n := 0
+	trie.Range(func(_, _ interface{}) bool {
+		n++
+		return true
+	})
+	require.Equal(t, 5, n)
+}
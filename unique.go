@@ -4,10 +4,15 @@ import "sync"
 
 type UniqueIndex struct {
 	x sync.Map
+	counter
 }
 
 func (i *UniqueIndex) LoadOrStore(key, value interface{}) (interface{}, bool) {
-	return i.x.LoadOrStore(key, value)
+	v, ok := i.x.LoadOrStore(key, value)
+	if !ok {
+		i.add(key, 1)
+	}
+	return v, ok
 }
 
 func (i *UniqueIndex) Range(f func(key, value interface{}) bool) {
@@ -23,5 +28,9 @@ func (i *UniqueIndex) Load(key interface{}) ([]interface{}, bool) {
 }
 
 func (i *UniqueIndex) LoadAndDelete(key, _ interface{}) (interface{}, bool) {
-	return i.x.LoadAndDelete(key)
+	v, ok := i.x.LoadAndDelete(key)
+	if ok {
+		i.remove(key, 0)
+	}
+	return v, ok
 }
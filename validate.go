@@ -0,0 +1,131 @@
+package memdb
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+var ErrValidation = errors.New("memdb: validation failed")
+
+// Rule is a named check of items written to a collection. Check returns the reason an item
+// violates the rule, Field names the fields the rule is about.
+type Rule struct {
+	Name  string
+	Field []string
+	Check func(Item) error
+}
+
+// Violation is a rule an item violates.
+type Violation struct {
+	Rule   string
+	Field  []string
+	Reason string
+}
+
+// ValidationError reports every rule an item violates.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	s := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		s = append(s, fmt.Sprintf("%s: %s: %s", v.Rule, strings.Join(v.Field, ", "), v.Reason))
+	}
+	return fmt.Sprintf("%v: %s", ErrValidation, strings.Join(s, "; "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+// Required is a rule of a field which is not nil and, for strings, slices and maps, not empty.
+func Required(field string) Rule {
+	return Rule{Name: "required", Field: []string{field}, Check: func(item Item) error {
+		value := item.Field(field)
+		if isNil(value) {
+			return errors.New("is required")
+		}
+		switch v := reflect.ValueOf(value); v.Kind() {
+		case reflect.String, reflect.Slice, reflect.Map:
+			if v.Len() == 0 {
+				return errors.New("is empty")
+			}
+		}
+		return nil
+	}}
+}
+
+// Between is a rule of a number field from min to max, nil passes.
+func Between(field string, min, max float64) Rule {
+	return Rule{Name: "between", Field: []string{field}, Check: func(item Item) error {
+		value := item.Field(field)
+		if isNil(value) {
+			return nil
+		}
+		var f float64
+		switch v := reflect.ValueOf(value); v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f = float64(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			f = float64(v.Uint())
+		case reflect.Float32, reflect.Float64:
+			f = v.Float()
+		default:
+			return fmt.Errorf("%T is not a number", value)
+		}
+		if f < min || f > max {
+			return fmt.Errorf("%v is not between %v and %v", value, min, max)
+		}
+		return nil
+	}}
+}
+
+// Match is a rule of a string field matching the expression, nil passes.
+func Match(field string, re *regexp.Regexp) Rule {
+	return Rule{Name: "match", Field: []string{field}, Check: func(item Item) error {
+		value := item.Field(field)
+		if isNil(value) {
+			return nil
+		}
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%T is not a string", value)
+		}
+		if !re.MatchString(s) {
+			return fmt.Errorf("%q does not match %s", s, re)
+		}
+		return nil
+	}}
+}
+
+// AddRule adds validation rules to the collection, Put and Store refuse items violating them.
+func (c *Collection) AddRule(rules ...Rule) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.rules = append(c.rules[:len(c.rules):len(c.rules)], rules...)
+}
+
+// Validate returns the rules of the collection the item violates as a *ValidationError, nil if
+// it violates none.
+func (c *Collection) Validate(item Item) error {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	return c.validate(item)
+}
+
+func (c *Collection) validate(item Item) error {
+	var violations []Violation
+	for _, rule := range c.rules {
+		if err := rule.Check(item); err != nil {
+			violations = append(violations, Violation{Rule: rule.Name, Field: rule.Field, Reason: err.Error()})
+		}
+	}
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
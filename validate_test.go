@@ -0,0 +1,44 @@
+package memdb
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollection_AddRule(t *testing.T) {
+	collection := newCollection(t)
+	collection.AddRule(
+		Required("type"),
+		Between("code", 0, 100),
+		Match("type", regexp.MustCompile(`^[a-z]*$`)),
+		Rule{Name: "name_below_code", Field: []string{"name", "code"}, Check: func(item Item) error {
+			if item.Field("name").(int) >= item.Field("code").(int) {
+				return fmt.Errorf("name %v is not below code %v", item.Field("name"), item.Field("code"))
+			}
+			return nil
+		}},
+	)
+	_, err := collection.Store(&Tx{}, X1{ID: uuid.New(), Type: "audio", Name: 1, Code: 2}, 0)
+	require.NoError(t, err)
+
+	_, err = collection.Store(&Tx{}, X1{ID: uuid.New(), Type: "", Name: 300, Code: 200}, 0)
+	require.ErrorIs(t, err, ErrValidation)
+	var validation *ValidationError
+	require.True(t, errors.As(err, &validation))
+	require.Equal(t, []Violation{
+		{Rule: "required", Field: []string{"type"}, Reason: "is empty"},
+		{Rule: "between", Field: []string{"code"}, Reason: "200 is not between 0 and 100"},
+		{Rule: "name_below_code", Field: []string{"name", "code"}, Reason: "name 300 is not below code 200"},
+	}, validation.Violations)
+
+	item := X1{ID: uuid.New(), Type: "Video", Name: 1, Code: 2}
+	require.EqualError(t, collection.Validate(item), "memdb: validation failed: match: type: \"Video\" does not match ^[a-z]*$")
+	_, ok := collection.Put(&Tx{}, item, 0)
+	require.False(t, ok)
+	require.Len(t, collection.all(&Tx{}), 1)
+}
@@ -0,0 +1,349 @@
+package memdb
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Metric is the distance function of a vector index.
+type Metric uint8
+
+const (
+	Cosine Metric = iota
+	L2
+	Dot
+)
+
+// Embedding is an Indexer which encodes a []float32 or []float64 value as a binary key.
+func Embedding(values ...interface{}) string {
+	var b strings.Builder
+	var buf [4]byte
+	for _, value := range values {
+		switch v := value.(type) {
+		case []float32:
+			for _, f := range v {
+				binary.LittleEndian.PutUint32(buf[:], math.Float32bits(f))
+				b.Write(buf[:])
+			}
+		case []float64:
+			for _, f := range v {
+				binary.LittleEndian.PutUint32(buf[:], math.Float32bits(float32(f)))
+				b.Write(buf[:])
+			}
+		}
+	}
+	return b.String()
+}
+
+func vectorOf(key string) []float32 {
+	vector := make([]float32, len(key)/4)
+	for n := range vector {
+		vector[n] = math.Float32frombits(binary.LittleEndian.Uint32([]byte(key[4*n : 4*n+4])))
+	}
+	return vector
+}
+
+// VectorIndex answers nearest neighbour queries over keys made by the Embedding indexer. It
+// searches a hierarchical navigable small world graph of M neighbours per node, or compares
+// every vector when Exact is set. Deleted vectors stay in the graph as tombstones, revived when
+// stored again, until they outnumber the live ones and the graph is rebuilt without them.
+type VectorIndex struct {
+	Metric         Metric
+	Exact          bool
+	M              int
+	EfConstruction int
+	EfSearch       int
+	mx             sync.RWMutex
+	points         map[string]*vertex
+	entry          *vertex
+	dead           int
+	random         *rand.Rand
+	counter
+}
+
+type vertex struct {
+	key       string
+	vector    []float32
+	rows      map[interface{}]struct{}
+	neighbors [][]*vertex
+}
+
+type candidate struct {
+	*vertex
+	distance float64
+}
+
+// candidates is a heap of candidates, the nearest on top unless far is set.
+type candidates struct {
+	items []candidate
+	far   bool
+}
+
+func (h candidates) Len() int { return len(h.items) }
+
+func (h candidates) Less(a, b int) bool {
+	return h.items[a].distance < h.items[b].distance != h.far
+}
+
+func (h candidates) Swap(a, b int) { h.items[a], h.items[b] = h.items[b], h.items[a] }
+
+func (h *candidates) Push(x interface{}) { h.items = append(h.items, x.(candidate)) }
+
+func (h *candidates) Pop() interface{} {
+	x := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return x
+}
+
+// Nearest returns items of the vector index i closest to the vector, nearest first.
+func (c *Collection) Nearest(tx *Tx, i int, vector []float32, k int) []Item {
+	return c.items(tx, c.index(i).Mapper.(*VectorIndex).Nearest(vector, k))
+}
+
+func (i *VectorIndex) distance(a, b []float32) float64 {
+	var dot, na, nb, l2 float64
+	for n := 0; n < len(a) && n < len(b); n++ {
+		x, y := float64(a[n]), float64(b[n])
+		dot += x * y
+		na += x * x
+		nb += y * y
+		l2 += (x - y) * (x - y)
+	}
+	switch i.Metric {
+	case L2:
+		return l2
+	case Dot:
+		return -dot
+	default:
+		if na == 0 || nb == 0 {
+			return 1
+		}
+		return 1 - dot/math.Sqrt(na*nb)
+	}
+}
+
+func (i *VectorIndex) m() int {
+	if i.M < 2 {
+		return 16
+	}
+	return i.M
+}
+
+func (i *VectorIndex) Load(key interface{}) (values []interface{}, ok bool) {
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	if v, ok := i.points[key.(string)]; ok {
+		for value := range v.rows {
+			values = append(values, value)
+		}
+	}
+	return values, len(values) > 0
+}
+
+func (i *VectorIndex) LoadOrStore(key, value interface{}) (interface{}, bool) {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	if i.points == nil {
+		i.points = make(map[string]*vertex)
+		i.random = rand.New(rand.NewSource(rand.Int63()))
+	}
+	v, ok := i.points[key.(string)]
+	if !ok {
+		v = &vertex{key: key.(string), vector: vectorOf(key.(string)), rows: map[interface{}]struct{}{}}
+		i.points[v.key] = v
+		if !i.Exact {
+			i.insert(v)
+		}
+	} else if len(v.rows) == 0 {
+		i.dead--
+	}
+	if _, ok := v.rows[value]; ok {
+		return value, true
+	}
+	v.rows[value] = struct{}{}
+	i.add(key, int64(len(v.rows)))
+	return value, false
+}
+
+func (i *VectorIndex) LoadAndDelete(key, value interface{}) (interface{}, bool) {
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	v, ok := i.points[key.(string)]
+	if !ok {
+		return nil, false
+	}
+	if _, ok := v.rows[value]; !ok {
+		return nil, false
+	}
+	delete(v.rows, value)
+	if len(v.rows) == 0 {
+		if i.Exact {
+			delete(i.points, v.key)
+		} else if i.dead++; 2*i.dead > len(i.points) {
+			i.rebuild()
+		}
+	}
+	i.remove(key, int64(len(v.rows)))
+	return value, true
+}
+
+// rebuild drops the tombstones and links the live vertices into a new graph.
+func (i *VectorIndex) rebuild() {
+	live := make([]*vertex, 0, len(i.points)-i.dead)
+	for key, v := range i.points {
+		if len(v.rows) == 0 {
+			delete(i.points, key)
+		} else {
+			live = append(live, v)
+		}
+	}
+	i.entry, i.dead = nil, 0
+	for _, v := range live {
+		i.insert(v)
+	}
+}
+
+func (i *VectorIndex) Range(f func(key, value interface{}) bool) {
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	for key, v := range i.points {
+		for value := range v.rows {
+			if !f(key, value) {
+				return
+			}
+		}
+	}
+}
+
+// Nearest returns the values of up to k vectors closest to the vector, nearest first.
+func (i *VectorIndex) Nearest(vector []float32, k int) []interface{} {
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	var found []candidate
+	if i.Exact {
+		for _, v := range i.points {
+			found = append(found, candidate{vertex: v, distance: i.distance(vector, v.vector)})
+		}
+	} else if i.entry != nil {
+		ef := i.EfSearch
+		if ef < k {
+			ef = k
+		}
+		entry := i.entry
+		for level := len(entry.neighbors) - 1; level > 0; level-- {
+			entry = i.search(vector, entry, 1, level)[0].vertex
+		}
+		found = i.search(vector, entry, ef+i.dead, 0)
+	}
+	sort.SliceStable(found, func(a, b int) bool {
+		return found[a].distance < found[b].distance
+	})
+	var values []interface{}
+	for _, c := range found {
+		for value := range c.rows {
+			if len(values) == k {
+				return values
+			}
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func (i *VectorIndex) insert(v *vertex) {
+	m := i.m()
+	level := int(-math.Log(1-i.random.Float64()) / math.Log(float64(m)))
+	v.neighbors = make([][]*vertex, level+1)
+	if i.entry == nil {
+		i.entry = v
+		return
+	}
+	entry := i.entry
+	for l := len(entry.neighbors) - 1; l > level; l-- {
+		entry = i.search(v.vector, entry, 1, l)[0].vertex
+	}
+	ef := i.EfConstruction
+	if ef < m {
+		ef = 2 * m
+	}
+	for l := level; l >= 0; l-- {
+		if l >= len(i.entry.neighbors) {
+			continue
+		}
+		found := i.search(v.vector, entry, ef, l)
+		limit := m
+		if l == 0 {
+			limit = 2 * m
+		}
+		v.neighbors[l] = i.nearest(found, m)
+		for _, n := range v.neighbors[l] {
+			n.neighbors[l] = append(n.neighbors[l], v)
+			if len(n.neighbors[l]) > limit {
+				n.neighbors[l] = i.prune(n, n.neighbors[l], limit)
+			}
+		}
+		entry = found[0].vertex
+	}
+	if level >= len(i.entry.neighbors) {
+		i.entry = v
+	}
+}
+
+func (i *VectorIndex) nearest(found []candidate, m int) []*vertex {
+	sort.SliceStable(found, func(a, b int) bool {
+		return found[a].distance < found[b].distance
+	})
+	var vertices []*vertex
+	for n := 0; n < len(found) && n < m; n++ {
+		vertices = append(vertices, found[n].vertex)
+	}
+	return vertices
+}
+
+func (i *VectorIndex) prune(v *vertex, neighbors []*vertex, limit int) []*vertex {
+	found := make([]candidate, 0, len(neighbors))
+	for _, n := range neighbors {
+		found = append(found, candidate{vertex: n, distance: i.distance(v.vector, n.vector)})
+	}
+	return i.nearest(found, limit)
+}
+
+// search returns up to ef vertices of the level closest to the vector, nearest first.
+func (i *VectorIndex) search(vector []float32, entry *vertex, ef, level int) []candidate {
+	first := candidate{vertex: entry, distance: i.distance(vector, entry.vector)}
+	visited := map[*vertex]bool{entry: true}
+	near := &candidates{items: []candidate{first}}
+	far := &candidates{items: []candidate{first}, far: true}
+	for near.Len() > 0 {
+		c := heap.Pop(near).(candidate)
+		if c.distance > far.items[0].distance && far.Len() >= ef {
+			break
+		}
+		if level >= len(c.neighbors) {
+			continue
+		}
+		for _, n := range c.neighbors[level] {
+			if visited[n] {
+				continue
+			}
+			visited[n] = true
+			d := i.distance(vector, n.vector)
+			if far.Len() < ef || d < far.items[0].distance {
+				heap.Push(near, candidate{vertex: n, distance: d})
+				heap.Push(far, candidate{vertex: n, distance: d})
+				if far.Len() > ef {
+					heap.Pop(far)
+				}
+			}
+		}
+	}
+	sort.SliceStable(far.items, func(a, b int) bool {
+		return far.items[a].distance < far.items[b].distance
+	})
+	return far.items
+}
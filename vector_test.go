@@ -0,0 +1,97 @@
+package memdb
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type V1 struct {
+	ID     int
+	Vector []float32
+}
+
+func (v V1) Copy(Item) (Item, bool) {
+	return v, true
+}
+
+func (v V1) Field(name string) interface{} {
+	switch name {
+	case "id":
+		return v.ID
+	case "vector":
+		return v.Vector
+	default:
+		panic(name)
+	}
+}
+
+func TestCollection_Nearest(t *testing.T) {
+	for _, metric := range []Metric{Cosine, L2, Dot} {
+		collection := newIndexed(
+			Index{
+				Field:   []string{"vector"},
+				Mapper:  &VectorIndex{Metric: metric, Exact: true},
+				Indexer: Embedding,
+			},
+			Index{
+				Field:   []string{"vector"},
+				Mapper:  &VectorIndex{Metric: metric, M: 8, EfSearch: 32},
+				Indexer: Embedding,
+			},
+		)
+		r := rand.New(rand.NewSource(int64(metric)))
+		vector := func() []float32 {
+			v := make([]float32, 8)
+			for n := range v {
+				v[n] = r.Float32()*2 - 1
+			}
+			return v
+		}
+		var items []Item
+		for i := 0; i < 500; i++ {
+			item := V1{ID: i, Vector: vector()}
+			_, ok := collection.Put(&Tx{}, item, 0)
+			require.True(t, ok)
+			items = append(items, item)
+		}
+		for _, item := range items[:100] {
+			_, ok := collection.Delete(&Tx{}, item, 0)
+			require.True(t, ok)
+		}
+		var hits, total int
+		for q := 0; q < 20; q++ {
+			query := vector()
+			exact := collection.Nearest(&Tx{}, 1, query, 10)
+			approx := collection.Nearest(&Tx{}, 2, query, 10)
+			require.Len(t, exact, 10)
+			require.Len(t, approx, 10)
+			for _, a := range approx {
+				require.GreaterOrEqual(t, a.(V1).ID, 100)
+				for _, e := range exact {
+					if a.(V1).ID == e.(V1).ID {
+						hits++
+					}
+				}
+			}
+			total += len(exact)
+		}
+		require.Greater(t, float64(hits)/float64(total), 0.8, "metric %d recall", metric)
+		if metric != Dot {
+			require.Equal(t, []Item{items[200]}, collection.Nearest(&Tx{}, 1, items[200].(V1).Vector, 1))
+			require.Equal(t, []Item{items[200]}, collection.Nearest(&Tx{}, 2, items[200].(V1).Vector, 1))
+		}
+		require.Equal(t, []Item{items[300]}, collection.Get(&Tx{}, 2, []interface{}{items[300].(V1).Vector}))
+
+		for _, item := range items[100:400] {
+			_, ok := collection.Delete(&Tx{}, item, 0)
+			require.True(t, ok)
+		}
+		index := collection.Indexes[2].Mapper.(*VectorIndex)
+		require.LessOrEqual(t, 2*index.dead, len(index.points))
+		require.Less(t, len(index.points), 300)
+		exact := collection.Nearest(&Tx{}, 1, items[450].(V1).Vector, 5)
+		require.Equal(t, exact, collection.Nearest(&Tx{}, 2, items[450].(V1).Vector, 5))
+	}
+}
@@ -0,0 +1,179 @@
+package memdb
+
+import "fmt"
+
+// Fault is the kind of inconsistency found by Verify.
+type Fault uint8
+
+const (
+	// Missing is a committed row absent from an index under its key.
+	Missing Fault = iota + 1
+	// Dangling is an index entry of a row which is not committed or not in the primary index.
+	Dangling
+	// Duplicate is a key of a unique index held by several live rows, it is not repaired.
+	Duplicate
+	// Stale is an index entry under a key which differs from the key of the row item.
+	Stale
+	// Unreadable is a committed row whose item can't be loaded, it is not repaired.
+	Unreadable
+)
+
+func (f Fault) String() string {
+	switch f {
+	case Missing:
+		return "missing"
+	case Dangling:
+		return "dangling"
+	case Duplicate:
+		return "duplicate"
+	case Stale:
+		return "stale"
+	case Unreadable:
+		return "unreadable"
+	}
+	return fmt.Sprintf("fault(%d)", uint8(f))
+}
+
+// Problem is an inconsistency of the index at position Index.
+type Problem struct {
+	Fault
+	Index    int
+	Key      string
+	Item     Item
+	Repaired bool
+	row      *Row
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("index %d: %v key %q", p.Index, p.Fault, p.Key)
+}
+
+// Verify checks that every index agrees with the primary index and repairs missing, dangling
+// and stale entries when repair is set. A primary row is dangling only when it is not committed,
+// a committed row which can't be loaded is reported unreadable. Writers are blocked while it
+// runs.
+func (c *Collection) Verify(tx *Tx, repair bool) []Problem {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	var problems []Problem
+	live := map[*Row]Item{}
+	c.Indexes[0].Range(func(key, value interface{}) bool {
+		row := value.(*Row)
+		row.rw.RLock()
+		cas, item := row.cas, row.item()
+		row.rw.RUnlock()
+		switch {
+		case cas == 0:
+			problems = append(problems, Problem{Fault: Dangling, Key: key.(string), row: row})
+		case item == nil:
+			problems = append(problems, Problem{Fault: Unreadable, Key: key.(string), row: row})
+			live[row] = nil
+		case c.Indexes[0].Key(item) != key:
+			problems = append(problems, Problem{Fault: Stale, Key: key.(string), Item: item, row: row})
+			fallthrough
+		default:
+			live[row] = item
+		}
+		return true
+	})
+	for i, index := range c.Indexes[1:] {
+		problems = append(problems, c.verify(tx, i+1, index, live)...)
+	}
+	if repair {
+		for n := range problems {
+			problems[n].Repaired = c.repair(problems[n])
+		}
+	}
+	return problems
+}
+
+// verify checks a secondary index against the live rows, entries of unreadable rows are left
+// alone since their keys are unknown.
+func (c *Collection) verify(tx *Tx, i int, index Index, live map[*Row]Item) (problems []Problem) {
+	unique := isUnique(index.Mapper)
+	expected := map[*Row]string{}
+	owners := map[string]int{}
+	for row, item := range live {
+		if item == nil {
+			continue
+		}
+		if key, ok := index.key(item, row); ok {
+			expected[row] = key
+			owners[key]++
+		}
+	}
+	seen := map[*Row]bool{}
+	rows := map[string]int{}
+	index.Range(func(key, value interface{}) bool {
+		row := value.(*Row)
+		rows[key.(string)]++
+		item, ok := live[row]
+		switch {
+		case !ok:
+			item, _, _ = row.get(tx)
+			problems = append(problems, Problem{Fault: Dangling, Index: i, Key: key.(string), Item: item, row: row})
+		case item == nil:
+		case expected[row] != key:
+			problems = append(problems, Problem{Fault: Stale, Index: i, Key: key.(string), Item: item, row: row})
+		default:
+			seen[row] = true
+		}
+		return true
+	})
+	if unique {
+		for key, n := range rows {
+			if n > 1 || owners[key] > 1 {
+				problems = append(problems, Problem{Fault: Duplicate, Index: i, Key: key})
+			}
+		}
+		for key, n := range owners {
+			if _, ok := rows[key]; !ok && n > 1 {
+				problems = append(problems, Problem{Fault: Duplicate, Index: i, Key: key})
+			}
+		}
+	}
+	for row, key := range expected {
+		if !seen[row] && !(unique && owners[key] > 1) {
+			problems = append(problems, Problem{Fault: Missing, Index: i, Key: key, Item: live[row], row: row})
+		}
+	}
+	return
+}
+
+func (c *Collection) repair(p Problem) bool {
+	index := c.Indexes[p.Index]
+	switch p.Fault {
+	case Stale:
+		if p.Index == 0 {
+			one, ok := index.Put(index.Key(p.Item), p.row)
+			if ok && one != p.row {
+				return false
+			}
+		}
+		_, ok := index.Pop(p.Key, p.row)
+		return ok
+	case Dangling:
+		_, ok := index.Pop(p.Key, p.row)
+		return ok
+	case Missing:
+		one, ok := index.Put(p.Key, p.row)
+		return !ok || one == p.row
+	}
+	return false
+}
+
+func isUnique(m Mapper) bool {
+	switch m := m.(type) {
+	case *UniqueIndex:
+		return true
+	case *OrderedIndex:
+		return m.Unique
+	case *ShardedIndex:
+		return m.Unique
+	case *DiskIndex:
+		return m.Unique
+	case interface{ Unique() bool }:
+		return m.Unique()
+	}
+	return false
+}
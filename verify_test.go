@@ -0,0 +1,82 @@
+package memdb
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollection_Verify(t *testing.T) {
+	item := []Item{
+		X1{ID: uuid.New(), Type: "verify", Code: 1, Name: 1},
+		X1{ID: uuid.New(), Type: "verify", Code: 2, Name: 2},
+		X1{ID: uuid.New(), Type: "verify", Code: 3, Name: 3},
+	}
+	collection := newCollection(t, item...)
+	require.Empty(t, collection.Verify(&Tx{}, false))
+	row := collection.Indexes[0].Get(collection.Indexes[0].Key(item[0]))[0]
+	collection.Indexes[2].LoadAndDelete(collection.Indexes[2].Key(item[0]), row)
+	collection.Indexes[1].Put(Format("verify", 9), &Row{})
+	collection.Indexes[3].Put(Format(time.Unix(1, 0)), row)
+	problems := collection.Verify(&Tx{}, false)
+	require.Len(t, problems, 3)
+	faults := map[Fault]Problem{}
+	for _, p := range problems {
+		faults[p.Fault] = p
+	}
+	require.Equal(t, 2, faults[Missing].Index)
+	require.Equal(t, item[0], faults[Missing].Item)
+	require.Equal(t, 1, faults[Dangling].Index)
+	require.Equal(t, Format("verify", 9), faults[Dangling].Key)
+	require.Equal(t, 3, faults[Stale].Index)
+	for _, p := range collection.Verify(&Tx{}, true) {
+		require.True(t, p.Repaired, p.String())
+	}
+	require.Empty(t, collection.Verify(&Tx{}, false))
+	require.Equal(t, []Item{item[0]}, collection.Get(&Tx{}, 2, []interface{}{1}))
+
+	row = collection.Indexes[0].Get(collection.Indexes[0].Key(item[1]))[0]
+	row.Item = X1{ID: item[1].(X1).ID, Type: "verify", Code: 1, Name: 2}
+	problems = collection.Verify(&Tx{}, false)
+	faults = map[Fault]Problem{}
+	for _, p := range problems {
+		faults[p.Fault] = p
+	}
+	require.Len(t, problems, 2, problems)
+	require.Equal(t, Problem{Fault: Duplicate, Index: 2, Key: Format(1)}, faults[Duplicate])
+	require.Equal(t, Format(2), faults[Stale].Key)
+
+	pending := X1{ID: uuid.New()}
+	collection.Indexes[0].Put(collection.Indexes[0].Key(pending), &Row{})
+	_, ok := collection.Delete(&Tx{}, pending, 0)
+	require.False(t, ok)
+	faults = map[Fault]Problem{}
+	for _, p := range collection.Verify(&Tx{}, false) {
+		faults[p.Fault] = p
+	}
+	require.Equal(t, collection.Indexes[0].Key(pending), faults[Dangling].Key)
+}
+
+type brokenPager struct{}
+
+func (brokenPager) save(*Row, Item) error { return nil }
+
+func (brokenPager) load(*Row) (Item, error) { return nil, errors.New("broken") }
+
+func (brokenPager) drop(*Row) {}
+
+func TestCollection_Verify_unreadable(t *testing.T) {
+	item := X1{ID: uuid.New(), Type: "verify", Code: 1, Name: 1}
+	collection := newCollection(t, item)
+	row := collection.Indexes[0].Get(collection.Indexes[0].Key(item))[0]
+	row.pager, row.page = brokenPager{}, 1
+	problems := collection.Verify(&Tx{}, true)
+	require.Len(t, problems, 1)
+	require.Equal(t, Unreadable, problems[0].Fault)
+	require.False(t, problems[0].Repaired)
+	require.Len(t, collection.Indexes[0].Get(collection.Indexes[0].Key(item)), 1)
+	require.Len(t, collection.Indexes[1].Get(collection.Indexes[1].Key(item)), 1)
+}